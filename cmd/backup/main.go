@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+	"webproject/database"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func main() {
+	dbPath := flag.String("db", "test.db", "database to back up or restore into")
+	dir := flag.String("backups", "backups", "directory the backups are kept in")
+	keep := flag.Int("keep", 7, "number of backups to keep, 0 keeps them all")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "usage: backup [flags] list | create | restore NAME")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	db, err := gorm.Open(sqlite.Open(*dbPath), &gorm.Config{})
+	if err != nil {
+		log.Fatalf("failed to open database: %v", err)
+	}
+	backups := &database.Backups{DB: db, Dir: *dir, Keep: *keep}
+
+	switch flag.Arg(0) {
+	case "list":
+		list, err := backups.List()
+		if err != nil {
+			log.Fatalf("failed to list backups: %v", err)
+		}
+		for _, backup := range list {
+			fmt.Printf("%s  %10d bytes  %s\n", backup.Name, backup.Size, backup.CreatedAt.Local().Format(time.DateTime))
+		}
+	case "create":
+		backup, err := backups.Create(time.Now())
+		if err != nil {
+			log.Fatalf("failed to back up: %v", err)
+		}
+		fmt.Printf("backed up to %s\n", backup.Name)
+	case "restore":
+		if flag.NArg() != 2 {
+			flag.Usage()
+			os.Exit(2)
+		}
+		// safe while the server is running, it sees the restored database
+		undo, err := backups.Restore(flag.Arg(1), time.Now())
+		if errors.Is(err, database.ErrBackupNotFound) {
+			log.Fatalf("no backup named %s in %s", flag.Arg(1), *dir)
+		}
+		if err != nil {
+			log.Fatalf("failed to restore: %v", err)
+		}
+		fmt.Printf("restored %s, the database before is in %s\n", flag.Arg(1), undo.Name)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
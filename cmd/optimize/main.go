@@ -0,0 +1,86 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+	"webproject/database"
+	"webproject/spacedrepetition"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func main() {
+	cfg := spacedrepetition.DefaultOptimizerConfig()
+
+	dbPath := flag.String("db", "test.db", "database with the review history")
+	deckID := flag.Uint("deck", 0, "deck to train on, 0 uses every deck")
+	userID := flag.Uint("user", 0, "user whose review history to train on, 0 is the admin")
+	save := flag.Bool("save", false, "store the fitted weights in the deck's options")
+	flag.IntVar(&cfg.Iterations, "iterations", cfg.Iterations, "optimiser steps")
+	flag.Float64Var(&cfg.LearningRate, "rate", cfg.LearningRate, "optimiser learning rate")
+	flag.Parse()
+
+	if *save && *deckID == 0 {
+		log.Fatal("-save needs a -deck to save the weights to")
+	}
+
+	db, err := gorm.Open(sqlite.Open(*dbPath), &gorm.Config{})
+	if err != nil {
+		log.Fatalf("failed to open database: %v", err)
+	}
+	if err := database.Migrate(db); err != nil {
+		log.Fatalf("failed to migrate database: %v", err)
+	}
+	if *userID == 0 {
+		if *userID, err = database.DefaultUserID(db); err != nil {
+			log.Fatalf("failed to find the admin: %v", err)
+		}
+	}
+	gormDB := &database.GormDB{DB: db, UserID: *userID}
+
+	initial := spacedrepetition.DefaultFSRSWeights
+	if *deckID != 0 {
+		options, err := gormDB.GetDeckOptions(*deckID)
+		if err != nil {
+			log.Fatalf("failed to load deck options: %v", err)
+		}
+		initial = spacedrepetition.ValidFSRSWeights(options.FSRSWeights)
+	}
+
+	logs, err := gormDB.GetReviewLogs(*deckID)
+	if err != nil {
+		log.Fatalf("failed to load review history: %v", err)
+	}
+
+	set := spacedrepetition.BuildTrainingSet(logs)
+	result, err := spacedrepetition.OptimizeFSRS(set, initial, cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Printf("trained on %d reviews of %d cards\n", result.Reviews, result.Cards)
+	fmt.Printf("before: log loss %.4f, rmse %.4f\n", result.Before.LogLoss, result.Before.RMSE)
+	fmt.Printf("after:  log loss %.4f, rmse %.4f\n", result.After.LogLoss, result.After.RMSE)
+
+	weights := make([]string, len(result.Weights))
+	for i, w := range result.Weights {
+		weights[i] = fmt.Sprintf("%.4f", w)
+	}
+	fmt.Printf("weights: %s\n", strings.Join(weights, ", "))
+
+	if *save {
+		options, err := gormDB.GetDeckOptions(*deckID)
+		if err != nil {
+			log.Fatalf("failed to load deck options: %v", err)
+		}
+		options.DeckID = *deckID
+		options.FSRSWeights = result.Weights
+		if err := gormDB.SaveDeckOptions(options); err != nil {
+			log.Fatalf("failed to save deck options: %v", err)
+		}
+		fmt.Printf("saved to deck %d\n", *deckID)
+	}
+}
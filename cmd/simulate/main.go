@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"webproject/database"
+	"webproject/models"
+	"webproject/simulation"
+	"webproject/spacedrepetition"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func main() {
+	cfg := simulation.DefaultConfig()
+
+	dbPath := flag.String("db", "test.db", "database to read the deck from")
+	deckID := flag.Uint("deck", 0, "deck to replay, 0 uses synthetic cards")
+	userID := flag.Uint("user", 0, "user whose deck options to replay with, 0 is the admin")
+	synthetic := flag.Int("cards", 500, "number of synthetic cards when no deck is given")
+	asJSON := flag.Bool("json", false, "print the report as JSON")
+	retention := flag.Float64("retention", 0, "desired retention, 0 keeps the deck's setting")
+	flag.IntVar(&cfg.Days, "days", cfg.Days, "number of days to simulate")
+	flag.IntVar(&cfg.NewCardsPerDay, "new", cfg.NewCardsPerDay, "new cards learned per day")
+	flag.IntVar(&cfg.ReviewsPerDay, "reviews", cfg.ReviewsPerDay, "max reviews per day, 0 for unlimited")
+	flag.Float64Var(&cfg.LearnAccuracy, "accuracy", cfg.LearnAccuracy, "chance of answering a learning card correctly")
+	flag.Float64Var(&cfg.InitialStability, "stability", cfg.InitialStability, "days a newly learned card is remembered at 90%")
+	flag.Uint64Var(&cfg.Seed, "seed", cfg.Seed, "random seed")
+	flag.Parse()
+
+	var cards []models.Card
+	options := models.DeckOptions{DesiredRetention: spacedrepetition.DefaultDesiredRetention}
+	if *deckID == 0 {
+		cards = simulation.SyntheticCards(*synthetic)
+	} else {
+		db, err := gorm.Open(sqlite.Open(*dbPath), &gorm.Config{})
+		if err != nil {
+			log.Fatalf("failed to open database: %v", err)
+		}
+		if err := database.Migrate(db); err != nil {
+			log.Fatalf("failed to migrate database: %v", err)
+		}
+		if *userID == 0 {
+			if *userID, err = database.DefaultUserID(db); err != nil {
+				log.Fatalf("failed to find the admin: %v", err)
+			}
+		}
+		source := &database.GormDB{DB: db, UserID: *userID}
+		cards, err = source.GetAllCardsByDeckID(*deckID)
+		if err != nil {
+			log.Fatalf("failed to load deck %d: %v", *deckID, err)
+		}
+		options, err = source.GetDeckOptions(*deckID)
+		if err != nil {
+			log.Fatalf("failed to load options of deck %d: %v", *deckID, err)
+		}
+	}
+	if *retention != 0 {
+		options.DesiredRetention = *retention
+	}
+
+	gormDB, fake, sandboxDeckID, err := simulation.NewSandbox(cards, options, cfg.Start)
+	if err != nil {
+		log.Fatalf("failed to set up simulation: %v", err)
+	}
+
+	report, err := simulation.Run(gormDB, fake, sandboxDeckID, cfg)
+	if err != nil {
+		log.Fatalf("simulation failed: %v", err)
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	fmt.Printf("%-5s %-10s %6s %8s %8s %10s\n", "day", "date", "new", "learning", "reviews", "retention")
+	for _, d := range report.Days {
+		dayRetention := "-"
+		if d.FirstReviews > 0 {
+			dayRetention = fmt.Sprintf("%.1f%%", 100*float64(d.FirstCorrect)/float64(d.FirstReviews))
+		}
+		fmt.Printf("%-5d %-10s %6d %8d %8d %10s\n",
+			d.Day, d.Date.Format("2006-01-02"), d.NewCards, d.LearningAnswers, d.Reviews, dayRetention)
+	}
+	fmt.Println()
+	fmt.Printf("desired retention:   %.0f%%\n", 100*options.DesiredRetention)
+	fmt.Printf("cards in deck:       %d\n", len(cards))
+	fmt.Printf("cards learned:       %d\n", report.CardsLearned)
+	fmt.Printf("total reviews:       %d\n", report.TotalReviews)
+	fmt.Printf("reviews per day:     %.1f avg, %d max\n", report.AverageReviewsPerDay, report.MaxReviewsPerDay)
+	fmt.Printf("retention:           %.1f%%\n", 100*report.Retention)
+}
@@ -0,0 +1,125 @@
+package database
+
+import (
+	"sort"
+	"time"
+	"webproject/models"
+)
+
+type ActivityDay struct {
+	Date         string  `json:"date"`
+	Reviews      int     `json:"reviews"`
+	Correct      int     `json:"correct"`
+	CorrectRate  float64 `json:"correct_rate"`
+	StudySeconds float64 `json:"study_seconds"`
+	// average over timed answers only
+	AverageAnswerSeconds float64 `json:"average_answer_seconds"`
+	timedAnswers         int
+	timedSeconds         float64
+}
+
+type Streaks struct {
+	Current      int  `json:"current"`
+	Longest      int  `json:"longest"`
+	StudiedToday bool `json:"studied_today"`
+}
+
+// GetActivity returns one entry per study day in [from, to), including days
+// without any reviews so a heatmap can be drawn directly. deckID 0 covers
+// every deck.
+func (g *GormDB) GetActivity(deckID uint, from time.Time, to time.Time, boundary DayBoundary) ([]ActivityDay, error) {
+	var logs []models.ReviewLog
+	query := g.reviewLogs().Where("reviewed_at >= ? AND reviewed_at < ?", from.UTC(), to.UTC()).
+		Order("reviewed_at ASC, id ASC")
+	if deckID != 0 {
+		query = query.Where("deck_id = ?", deckID)
+	}
+	if err := query.Find(&logs).Error; err != nil {
+		return nil, err
+	}
+
+	var days []ActivityDay
+	index := map[string]int{}
+	for day := boundary.StartOfDay(from); day.Before(to); day = day.AddDate(0, 0, 1) {
+		index[boundary.Date(day)] = len(days)
+		days = append(days, ActivityDay{Date: boundary.Date(day)})
+	}
+
+	for i, l := range logs {
+		at, ok := index[boundary.Date(l.ReviewedAt)]
+		if !ok {
+			continue
+		}
+		day := &days[at]
+		day.Reviews++
+		if l.Correct {
+			day.Correct++
+		}
+		if l.DurationMs > 0 {
+			seconds := float64(l.DurationMs) / 1000
+			day.StudySeconds += seconds
+			day.timedAnswers++
+			day.timedSeconds += seconds
+		} else if i > 0 {
+			gap := l.ReviewedAt.Sub(logs[i-1].ReviewedAt)
+			if gap > 0 && gap <= maxStudyGap {
+				day.StudySeconds += gap.Seconds()
+			}
+		}
+	}
+
+	for i := range days {
+		if days[i].Reviews > 0 {
+			days[i].CorrectRate = float64(days[i].Correct) / float64(days[i].Reviews)
+		}
+		if days[i].timedAnswers > 0 {
+			days[i].AverageAnswerSeconds = days[i].timedSeconds / float64(days[i].timedAnswers)
+		}
+	}
+	return days, nil
+}
+
+// A streak survives until the end of today, so not having studied yet today
+// doesn't break it.
+func (g *GormDB) GetStreaks(boundary DayBoundary) (Streaks, error) {
+	var reviewedAt []time.Time
+	if err := g.reviewLogs().Pluck("reviewed_at", &reviewedAt).Error; err != nil {
+		return Streaks{}, err
+	}
+
+	studied := map[int]bool{}
+	for _, t := range reviewedAt {
+		studied[boundary.dayNumber(t)] = true
+	}
+
+	var streaks Streaks
+	today := boundary.dayNumber(g.Now())
+	streaks.StudiedToday = studied[today]
+
+	day := today
+	if !streaks.StudiedToday {
+		day--
+	}
+	for studied[day] {
+		streaks.Current++
+		day--
+	}
+
+	sorted := make([]int, 0, len(studied))
+	for d := range studied {
+		sorted = append(sorted, d)
+	}
+	sort.Ints(sorted)
+
+	run := 0
+	for i, d := range sorted {
+		if i > 0 && d == sorted[i-1]+1 {
+			run++
+		} else {
+			run = 1
+		}
+		streaks.Longest = max(streaks.Longest, run)
+	}
+
+	return streaks, nil
+}
@@ -0,0 +1,91 @@
+package database
+
+import (
+	"errors"
+	"time"
+	"webproject/models"
+)
+
+// TokenScopes from least to most access, each allowing what the ones
+// before it do
+var TokenScopes = []string{"read", "study", "edit", "admin"}
+
+var (
+	ErrUnknownScope  = errors.New("scope must be read, study, edit or admin")
+	ErrTokenNotFound = errors.New("token not found")
+)
+
+func scopeLevel(scope string) int {
+	for i, s := range TokenScopes {
+		if s == scope {
+			return i
+		}
+	}
+	return -1
+}
+
+// ScopeAllows reports whether a token with scope have may do what needs
+// scope need
+func ScopeAllows(have string, need string) bool {
+	level := scopeLevel(have)
+	return level >= 0 && level >= scopeLevel(need)
+}
+
+// CreateAPIToken returns the new token, which is only ever shown here
+func (g *GormDB) CreateAPIToken(name string, scope string, expiresAt *time.Time) (string, models.APIToken, error) {
+	if scopeLevel(scope) < 0 {
+		return "", models.APIToken{}, ErrUnknownScope
+	}
+	token, err := newToken()
+	if err != nil {
+		return "", models.APIToken{}, err
+	}
+
+	apiToken := models.APIToken{
+		UserID:    g.UserID,
+		Name:      name,
+		Scope:     scope,
+		TokenHash: hashToken(token),
+		CreatedAt: g.Now(),
+		ExpiresAt: expiresAt,
+	}
+	err = g.DB.Create(&apiToken).Error
+	return token, apiToken, err
+}
+
+func (g *GormDB) ListAPITokens() ([]models.APIToken, error) {
+	var tokens []models.APIToken
+	err := g.DB.Where("user_id = ?", g.UserID).Order("created_at DESC, id DESC").Find(&tokens).Error
+	return tokens, err
+}
+
+// DeleteAPIToken revokes one of the user's tokens
+func (g *GormDB) DeleteAPIToken(id uint) error {
+	result := g.DB.Where("id = ? AND user_id = ?", id, g.UserID).Delete(&models.APIToken{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrTokenNotFound
+	}
+	return nil
+}
+
+// GetAPITokenUser returns the user and scope of a token that hasn't expired,
+// and notes that it was used
+func (g *GormDB) GetAPITokenUser(token string) (models.User, string, error) {
+	now := g.Now()
+	var apiToken models.APIToken
+	err := g.DB.Where("token_hash = ? AND (expires_at IS NULL OR expires_at > ?)", hashToken(token), now).
+		First(&apiToken).Error
+	if err != nil {
+		return models.User{}, "", err
+	}
+	if err := g.DB.Model(&apiToken).Update("last_used_at", now).Error; err != nil {
+		return models.User{}, "", err
+	}
+
+	var user models.User
+	err = g.DB.First(&user, apiToken.UserID).Error
+	return user, apiToken.Scope, err
+}
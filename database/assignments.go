@@ -0,0 +1,353 @@
+package database
+
+import (
+	"errors"
+	"time"
+	"webproject/models"
+	"webproject/search"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrDeckNotInClass     = errors.New("the deck must be shared with the class first")
+	ErrAssignmentNotFound = errors.New("assignment not found")
+)
+
+type AssignmentProgress struct {
+	UserID   uint   `json:"user_id"`
+	Username string `json:"username"`
+	Cards    int64  `json:"cards"` // cards the assignment covers
+	Learned  int64  `json:"learned"`
+	Target   int64  `json:"target"`
+	// answers to the assignment's cards since it was set, cram left out
+	Retention   RetentionStats `json:"retention"`
+	Status      string         `json:"status"` // open, overdue, completed or late
+	CompletedAt *time.Time     `json:"completed_at"`
+}
+
+type StudentAssignment struct {
+	Assignment models.Assignment  `json:"assignment"`
+	ClassName  string             `json:"class_name"`
+	Progress   AssignmentProgress `json:"progress"`
+}
+
+// checkClassDeck returns ErrDeckNotInClass unless the deck was shared with
+// the class
+func (g *GormDB) checkClassDeck(classID uint, deckID uint) error {
+	var linked int64
+	err := g.DB.Model(&models.ClassDeck{}).
+		Where("class_id = ? AND deck_id = ?", classID, deckID).
+		Count(&linked).Error
+	if err != nil {
+		return err
+	}
+	if linked == 0 {
+		return ErrDeckNotInClass
+	}
+	return nil
+}
+
+// CreateAssignment checks the deck is one of the class's and the query
+// parses before saving
+func (g *GormDB) CreateAssignment(assignment models.Assignment) (models.Assignment, error) {
+	if err := g.checkClassDeck(assignment.ClassID, assignment.DeckID); err != nil {
+		return assignment, err
+	}
+	if _, err := search.Parse(assignment.Query); err != nil {
+		return assignment, err
+	}
+
+	assignment.CreatedAt = g.Now()
+	if err := g.DB.Create(&assignment).Error; err != nil {
+		return assignment, err
+	}
+
+	// students who already know the cards have met it as soon as it is set
+	students, err := g.GetClassStudents(assignment.ClassID)
+	if err != nil {
+		return assignment, err
+	}
+	for _, student := range students {
+		db := g.ForUser(student.UserID)
+		boundary, err := db.GetDayBoundary()
+		if err != nil {
+			return assignment, err
+		}
+		cards, err := db.assignmentCards(assignment)
+		if err != nil {
+			return assignment, err
+		}
+		if err := db.recordCompletion(assignment, cards, boundary, assignment.CreatedAt); err != nil {
+			return assignment, err
+		}
+	}
+	return assignment, nil
+}
+
+func (g *GormDB) GetClassAssignments(classID uint) ([]models.Assignment, error) {
+	assignments := []models.Assignment{}
+	err := g.DB.Where("class_id = ?", classID).Order("due_at ASC, id ASC").Find(&assignments).Error
+	return assignments, err
+}
+
+func (g *GormDB) GetAssignment(classID uint, id uint) (models.Assignment, error) {
+	var assignment models.Assignment
+	err := g.DB.Where("class_id = ?", classID).First(&assignment, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return assignment, ErrAssignmentNotFound
+	}
+	return assignment, err
+}
+
+func (g *GormDB) DeleteAssignment(classID uint, id uint) error {
+	return g.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("class_id = ?", classID).Delete(&models.Assignment{}, id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrAssignmentNotFound
+		}
+		return tx.Where("assignment_id = ?", id).Delete(&models.AssignmentCompletion{}).Error
+	})
+}
+
+// AssignmentProgress measures how far the user is with an assignment. The
+// completion is recorded when the answer that meets the targets is saved.
+func (g *GormDB) AssignmentProgress(assignment models.Assignment, boundary DayBoundary) (AssignmentProgress, error) {
+	progress, err := g.measureAssignment(assignment, boundary)
+	if err != nil {
+		return progress, err
+	}
+	now := g.Now()
+
+	var completion models.AssignmentCompletion
+	found := g.DB.Where("assignment_id = ? AND user_id = ?", assignment.ID, g.UserID).Limit(1).Find(&completion)
+	if found.Error != nil {
+		return progress, found.Error
+	}
+
+	switch {
+	case completion.ID != 0 && completion.CompletedAt.After(assignment.DueAt):
+		progress.Status = "late"
+	case completion.ID != 0:
+		progress.Status = "completed"
+	case now.After(assignment.DueAt):
+		progress.Status = "overdue"
+	}
+	if completion.ID != 0 {
+		progress.CompletedAt = &completion.CompletedAt
+	}
+	return progress, nil
+}
+
+func (g *GormDB) measureAssignment(assignment models.Assignment, boundary DayBoundary) (AssignmentProgress, error) {
+	cards, err := g.assignmentCards(assignment)
+	if err != nil {
+		return AssignmentProgress{}, err
+	}
+	progress, err := g.countAssignment(assignment, cards)
+	if err != nil {
+		return progress, err
+	}
+	err = g.assignmentRetention(&progress, assignment, cards, boundary)
+	return progress, err
+}
+
+// assignmentCards starts a query over the cards the assignment covers, the
+// user's view of the deck and its subdecks narrowed down by the query
+func (g *GormDB) assignmentCards(assignment models.Assignment) (*gorm.DB, error) {
+	deckIDs, err := g.GetDeckSubtreeIDs(assignment.DeckID)
+	if err != nil {
+		return nil, err
+	}
+	cards := g.cards().Where("(cards.deck_id IN ? OR cards.home_deck_id IN ?)", deckIDs, deckIDs)
+	query, err := search.Parse(assignment.Query)
+	if err != nil {
+		return nil, err
+	}
+	if query != nil {
+		c := searchCompiler{g: g, fts: HasFTS5(g.DB)}
+		where, args, err := c.compile(query)
+		if err != nil {
+			return nil, err
+		}
+		cards = cards.Where("("+where+")", args...)
+	}
+	// callers build more than one query on it
+	return cards.Session(&gorm.Session{}), nil
+}
+
+// countAssignment fills in how many of the cards there are and are learned
+func (g *GormDB) countAssignment(assignment models.Assignment, cards *gorm.DB) (AssignmentProgress, error) {
+	progress := AssignmentProgress{UserID: g.UserID, Status: "open"}
+	var counts struct {
+		Cards   int64
+		Learned int64
+	}
+	err := cards.Select("COUNT(*) AS cards, COALESCE(SUM(cards.stage = 'review'), 0) AS learned").
+		Scan(&counts).Error
+	if err != nil {
+		return progress, err
+	}
+	progress.Cards, progress.Learned = counts.Cards, counts.Learned
+	progress.Target = progress.Cards
+	if assignment.TargetCards > 0 {
+		progress.Target = min(int64(assignment.TargetCards), progress.Cards)
+	}
+	return progress, nil
+}
+
+// assignmentRetention fills in the retention of answers to the cards since
+// the assignment was set
+func (g *GormDB) assignmentRetention(progress *AssignmentProgress, assignment models.Assignment, cards *gorm.DB, boundary DayBoundary) error {
+	var logs []models.ReviewLog
+	err := g.reviewLogs().
+		Where("card_id IN (?) AND reviewed_at >= ? AND cram = ?", cards.Select("cards.id"), assignment.CreatedAt, false).
+		Order("reviewed_at ASC, id ASC").
+		Find(&logs).Error
+	if err != nil {
+		return err
+	}
+	progress.Retention = trueRetention(logs, boundary)
+	progress.Retention.Days = int(g.Now().Sub(assignment.CreatedAt).Hours()/24) + 1
+	return nil
+}
+
+// recordCompletions records the user's completion of the open assignments
+// covering the card, if the correct answer to it given at the time met them.
+// Wrong answers can't meet an assignment that wasn't met already.
+func (g *GormDB) recordCompletions(cardID uint, at time.Time) error {
+	card, err := g.GetCardByID(cardID)
+	if err != nil {
+		return err
+	}
+	path, err := g.deckPath(homeDeckID(card))
+	if err != nil {
+		return err
+	}
+	var assignments []models.Assignment
+	err = g.DB.Model(&models.Assignment{}).
+		Joins("JOIN class_members ON class_members.class_id = assignments.class_id").
+		Where("class_members.user_id = ? AND assignments.deck_id IN ?", g.UserID, path).
+		Where("assignments.id NOT IN (SELECT assignment_id FROM assignment_completions WHERE user_id = ?)", g.UserID).
+		Find(&assignments).Error
+	if err != nil || len(assignments) == 0 {
+		return err
+	}
+
+	boundary, err := g.GetDayBoundary()
+	if err != nil {
+		return err
+	}
+	for _, assignment := range assignments {
+		cards, err := g.assignmentCards(assignment)
+		if err != nil {
+			return err
+		}
+		// the query may leave the card out
+		var covered int64
+		if err := cards.Where("cards.id = ?", cardID).Count(&covered).Error; err != nil {
+			return err
+		}
+		if covered == 0 {
+			continue
+		}
+		if err := g.recordCompletion(assignment, cards, boundary, at); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recordCompletion stores the time the user met the assignment's targets,
+// unless they haven't. Answers synced from before the assignment was set
+// count as completing it when it was set. The review log is only read when
+// the cards are learned and there is a retention target.
+func (g *GormDB) recordCompletion(assignment models.Assignment, cards *gorm.DB, boundary DayBoundary, at time.Time) error {
+	progress, err := g.countAssignment(assignment, cards)
+	if err != nil || progress.Cards == 0 || progress.Learned < progress.Target {
+		return err
+	}
+	if assignment.TargetRetention > 0 {
+		if err := g.assignmentRetention(&progress, assignment, cards, boundary); err != nil {
+			return err
+		}
+	}
+	if !progress.met(assignment) {
+		return nil
+	}
+	if at.Before(assignment.CreatedAt) {
+		at = assignment.CreatedAt
+	}
+	completion := models.AssignmentCompletion{AssignmentID: assignment.ID, UserID: g.UserID, CompletedAt: at}
+	return g.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&completion).Error
+}
+
+// an assignment without cards can't be met, there is nothing to learn yet
+func (p AssignmentProgress) met(assignment models.Assignment) bool {
+	if p.Cards == 0 || p.Learned < p.Target {
+		return false
+	}
+	if assignment.TargetRetention > 0 {
+		return p.Retention.Reviews > 0 && p.Retention.Rate >= assignment.TargetRetention
+	}
+	return true
+}
+
+// GetAssignmentProgress lists every student's progress on the assignment
+func (g *GormDB) GetAssignmentProgress(assignment models.Assignment, boundary DayBoundary) ([]AssignmentProgress, error) {
+	students, err := g.GetClassStudents(assignment.ClassID)
+	if err != nil {
+		return nil, err
+	}
+	all := make([]AssignmentProgress, 0, len(students))
+	for _, student := range students {
+		progress, err := g.ForUser(student.UserID).AssignmentProgress(assignment, boundary)
+		if err != nil {
+			return nil, err
+		}
+		progress.Username = student.Username
+		all = append(all, progress)
+	}
+	return all, nil
+}
+
+// GetStudentAssignments lists the assignments of every class the user is a
+// student of, soonest due first. Unless all is set, only the ones not yet
+// completed are included.
+func (g *GormDB) GetStudentAssignments(all bool, boundary DayBoundary) ([]StudentAssignment, error) {
+	var rows []struct {
+		models.Assignment
+		ClassName string
+	}
+	err := g.DB.Model(&models.Assignment{}).
+		Select("assignments.*, classes.name AS class_name").
+		Joins("JOIN classes ON classes.id = assignments.class_id").
+		Joins("JOIN class_members ON class_members.class_id = assignments.class_id").
+		Where("class_members.user_id = ?", g.UserID).
+		Order("assignments.due_at ASC, assignments.id ASC").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	assignments := []StudentAssignment{}
+	for _, row := range rows {
+		progress, err := g.AssignmentProgress(row.Assignment, boundary)
+		if err != nil {
+			return nil, err
+		}
+		if !all && progress.CompletedAt != nil {
+			continue
+		}
+		assignments = append(assignments, StudentAssignment{
+			Assignment: row.Assignment,
+			ClassName:  row.ClassName,
+			Progress:   progress,
+		})
+	}
+	return assignments, nil
+}
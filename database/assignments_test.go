@@ -0,0 +1,108 @@
+package database
+
+import (
+	"testing"
+	"time"
+	"webproject/clock"
+	"webproject/models"
+)
+
+// newTestAssignment sets up alice teaching bob a one card deck, with an
+// assignment to learn it due two days from the start
+func newTestAssignment(t *testing.T) (teacher *GormDB, student *GormDB, fake *clock.Fake, card models.Card, assignment models.Assignment) {
+	t.Helper()
+	alice, clk := newTestDB(t)
+	bob := alice.ForUser(2)
+	for _, name := range []string{"alice", "bob"} {
+		if _, err := alice.CreateUser(name, "password1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	deck, cards := newTestDeck(t, alice, "French", nil, "chat")
+	class, err := alice.CreateClass("French 101")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bob.JoinClass(class.JoinCode); err != nil {
+		t.Fatal(err)
+	}
+	if err := alice.AddClassDeck(class.ID, deck.ID); err != nil {
+		t.Fatal(err)
+	}
+	assignment, err = alice.CreateAssignment(models.Assignment{ClassID: class.ID, DeckID: deck.ID,
+		Title: "Animals", DueAt: testStart.Add(48 * time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return alice, bob, clk, cards[0], assignment
+}
+
+func learn(t *testing.T, g *GormDB, card models.Card) {
+	t.Helper()
+	for range 2 {
+		if _, err := g.UpdateLearningCardByID(card.ID, card.Answer, true, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestAssignmentCompletesWhenTheAnswerIsSaved(t *testing.T) {
+	_, bob, clk, card, assignment := newTestAssignment(t)
+
+	answeredAt := testStart.Add(24 * time.Hour)
+	clk.Set(answeredAt)
+	learn(t, bob, card)
+
+	// nobody looks until after the deadline
+	clk.Set(testStart.Add(72 * time.Hour))
+	progress, err := bob.AssignmentProgress(assignment, DayBoundary{Location: time.UTC})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if progress.Status != "completed" {
+		t.Errorf("status = %s, want completed", progress.Status)
+	}
+	if progress.CompletedAt == nil || !progress.CompletedAt.Equal(answeredAt) {
+		t.Errorf("completed at %v, want %v", progress.CompletedAt, answeredAt)
+	}
+}
+
+func TestAssignmentProgressDoesntWrite(t *testing.T) {
+	_, bob, clk, card, assignment := newTestAssignment(t)
+	learn(t, bob, card)
+	if err := bob.DB.Where("1 = 1").Delete(&models.AssignmentCompletion{}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	clk.Set(testStart.Add(72 * time.Hour))
+	progress, err := bob.AssignmentProgress(assignment, DayBoundary{Location: time.UTC})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var completions int64
+	if err := bob.DB.Model(&models.AssignmentCompletion{}).Count(&completions).Error; err != nil {
+		t.Fatal(err)
+	}
+	if completions != 0 || progress.Status != "overdue" {
+		t.Errorf("reading progress left %d completions and status %s, want none and overdue",
+			completions, progress.Status)
+	}
+}
+
+func TestAssignmentMetWhenSetIsCompletedThen(t *testing.T) {
+	alice, bob, _, card, assignment := newTestAssignment(t)
+	learn(t, bob, card)
+
+	again, err := alice.CreateAssignment(models.Assignment{ClassID: assignment.ClassID, DeckID: assignment.DeckID,
+		Title: "Again", DueAt: assignment.DueAt})
+	if err != nil {
+		t.Fatal(err)
+	}
+	progress, err := bob.AssignmentProgress(again, DayBoundary{Location: time.UTC})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if progress.Status != "completed" || !progress.CompletedAt.Equal(again.CreatedAt) {
+		t.Errorf("status %s at %v, want completed when it was set", progress.Status, progress.CompletedAt)
+	}
+}
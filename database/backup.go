@@ -0,0 +1,249 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"gorm.io/gorm"
+)
+
+const (
+	backupPrefix = "backup-"
+	backupSuffix = ".db"
+	// names sort in the order the backups were made
+	backupTimeLayout = "20060102-150405.000"
+)
+
+var ErrBackupNotFound = errors.New("backup not found")
+
+type Backup struct {
+	Name      string    `json:"name"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Backups are copies of the whole database in Dir, of which the newest Keep
+// are kept. 0 keeps them all.
+type Backups struct {
+	DB   *gorm.DB
+	Dir  string
+	Keep int
+}
+
+// Create copies the database while the server keeps using it, then deletes
+// the backups past Keep
+func (b *Backups) Create(at time.Time) (Backup, error) {
+	backup, err := b.vacuumInto(at)
+	if err != nil {
+		return backup, err
+	}
+	return backup, b.prune("")
+}
+
+// vacuumInto copies the database into a new backup. Backups made in the
+// same millisecond get -2, -3 and so on after the time, the name is claimed
+// with an empty file first as VACUUM INTO fills those in but won't overwrite
+// a backup.
+func (b *Backups) vacuumInto(at time.Time) (Backup, error) {
+	if err := os.MkdirAll(b.Dir, 0o755); err != nil {
+		return Backup{}, err
+	}
+	stamp := at.UTC().Format(backupTimeLayout)
+	var name, path string
+	for n := 1; ; n++ {
+		name = backupPrefix + stamp + backupSuffix
+		if n > 1 {
+			name = fmt.Sprintf("%s%s-%d%s", backupPrefix, stamp, n, backupSuffix)
+		}
+		path = filepath.Join(b.Dir, name)
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+		if errors.Is(err, os.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return Backup{}, err
+		}
+		if err := f.Close(); err != nil {
+			return Backup{}, err
+		}
+		break
+	}
+	if err := b.DB.Exec("VACUUM INTO ?", path).Error; err != nil {
+		os.Remove(path)
+		return Backup{}, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return Backup{}, err
+	}
+	return Backup{Name: name, Size: info.Size(), CreatedAt: at.UTC()}, nil
+}
+
+// List returns the backups newest first. Other files in Dir are left out.
+func (b *Backups) List() ([]Backup, error) {
+	entries, err := os.ReadDir(b.Dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return []Backup{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	backups := []Backup{}
+	seq := map[string]int{}
+	for _, entry := range entries {
+		createdAt, n, ok := backupTime(entry.Name())
+		if !ok || !entry.Type().IsRegular() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		backups = append(backups, Backup{Name: entry.Name(), Size: info.Size(), CreatedAt: createdAt})
+		seq[entry.Name()] = n
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		if !backups[i].CreatedAt.Equal(backups[j].CreatedAt) {
+			return backups[i].CreatedAt.After(backups[j].CreatedAt)
+		}
+		return seq[backups[i].Name] > seq[backups[j].Name]
+	})
+	return backups, nil
+}
+
+// backupTime reads the time a backup was made from its name, along with
+// which backup of that millisecond it is, counting from 1
+func backupTime(name string) (time.Time, int, bool) {
+	stamp, ok := strings.CutPrefix(name, backupPrefix)
+	if !ok {
+		return time.Time{}, 0, false
+	}
+	stamp, ok = strings.CutSuffix(stamp, backupSuffix)
+	if !ok || len(stamp) < len(backupTimeLayout) {
+		return time.Time{}, 0, false
+	}
+	t, err := time.Parse(backupTimeLayout, stamp[:len(backupTimeLayout)])
+	if err != nil {
+		return time.Time{}, 0, false
+	}
+	n := 1
+	if rest := stamp[len(backupTimeLayout):]; rest != "" {
+		digits, ok := strings.CutPrefix(rest, "-")
+		if n, err = strconv.Atoi(digits); !ok || err != nil || n < 2 || strconv.Itoa(n) != digits {
+			return time.Time{}, 0, false
+		}
+	}
+	return t, n, true
+}
+
+// prune deletes the backups past Keep, except the named one
+func (b *Backups) prune(except string) error {
+	if b.Keep <= 0 {
+		return nil
+	}
+	backups, err := b.List()
+	if err != nil || len(backups) <= b.Keep {
+		return err
+	}
+	for _, backup := range backups[b.Keep:] {
+		if backup.Name == except {
+			continue
+		}
+		if err := os.Remove(filepath.Join(b.Dir, backup.Name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Restore replaces the database's contents with the named backup while the
+// server keeps running. The database is backed up first, that backup is
+// returned so the restore can be undone. Sessions are restored along with
+// everything else, so users may have to log in again.
+func (b *Backups) Restore(name string, at time.Time) (Backup, error) {
+	if _, _, ok := backupTime(name); !ok || filepath.Base(name) != name {
+		return Backup{}, ErrBackupNotFound
+	}
+	path := filepath.Join(b.Dir, name)
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return Backup{}, ErrBackupNotFound
+	} else if err != nil {
+		return Backup{}, err
+	}
+
+	source, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		return Backup{}, err
+	}
+	defer source.Close()
+	var check string
+	if err := source.QueryRow("PRAGMA quick_check").Scan(&check); err != nil {
+		return Backup{}, err
+	}
+	if check != "ok" {
+		return Backup{}, errors.New("backup is damaged: " + check)
+	}
+
+	undo, err := b.vacuumInto(at)
+	if err != nil {
+		return undo, err
+	}
+	if err := copyDatabase(b.DB, source); err != nil {
+		return undo, err
+	}
+	// backups from an older version get the current schema
+	if err := Migrate(b.DB); err != nil {
+		return undo, err
+	}
+	// the restored backup stays even if it is past Keep now
+	return undo, b.prune(name)
+}
+
+// copyDatabase overwrites db with source page by page using sqlite's backup
+// API, which other connections to db see as a single change
+func copyDatabase(db *gorm.DB, source *sql.DB) error {
+	ctx := context.Background()
+	destDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	dest, err := destDB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer dest.Close()
+	src, err := source.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	return dest.Raw(func(destConn any) error {
+		return src.Raw(func(srcConn any) error {
+			to, ok := destConn.(*sqlite3.SQLiteConn)
+			from, ok2 := srcConn.(*sqlite3.SQLiteConn)
+			if !ok || !ok2 {
+				return errors.New("restoring a backup needs the sqlite3 driver")
+			}
+			backup, err := to.Backup("main", from, "main")
+			if err != nil {
+				return err
+			}
+			if _, err := backup.Step(-1); err != nil {
+				backup.Finish()
+				return err
+			}
+			return backup.Finish()
+		})
+	})
+}
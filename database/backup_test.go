@@ -0,0 +1,105 @@
+package database
+
+import (
+	"errors"
+	"testing"
+	"time"
+	"webproject/models"
+)
+
+func backupNames(t *testing.T, b *Backups) []string {
+	t.Helper()
+	list, err := b.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, backup := range list {
+		names = append(names, backup.Name)
+	}
+	return names
+}
+
+func TestBackupsMadeInTheSameMillisecond(t *testing.T) {
+	g, _ := newTestDB(t)
+	b := &Backups{DB: g.DB, Dir: t.TempDir(), Keep: 3}
+
+	for range 4 {
+		if _, err := b.Create(testStart); err != nil {
+			t.Fatal(err)
+		}
+	}
+	later, err := b.Create(testStart.Add(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		later.Name,
+		"backup-20260302-120000.000-4.db",
+		"backup-20260302-120000.000-3.db",
+	}
+	got := backupNames(t, b)
+	if len(got) != len(want) {
+		t.Fatalf("backups = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("backups = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestBackupNames(t *testing.T) {
+	tests := []struct {
+		name string
+		ok   bool
+		n    int
+	}{
+		{"backup-20260302-120000.000.db", true, 1},
+		{"backup-20260302-120000.000-2.db", true, 2},
+		{"backup-20260302-120000.000-12.db", true, 12},
+		{"backup-20260302-120000.000-1.db", false, 0},
+		{"backup-20260302-120000.000-02.db", false, 0},
+		{"backup-20260302-120000.000-x.db", false, 0},
+		{"backup-20260302-120000.000.db-journal", false, 0},
+		{"notes.txt", false, 0},
+	}
+	for _, tt := range tests {
+		at, n, ok := backupTime(tt.name)
+		if ok != tt.ok || n != tt.n || (ok && !at.Equal(testStart)) {
+			t.Errorf("backupTime(%s) = %v, %d, %v", tt.name, at, n, ok)
+		}
+	}
+}
+
+func TestRestoreKeepsTheRestoredBackup(t *testing.T) {
+	g, _ := newTestDB(t)
+	b := &Backups{DB: g.DB, Dir: t.TempDir(), Keep: 1}
+	before, _ := newTestDeck(t, g, "French", nil, "chat")
+	backup, err := b.Create(testStart)
+	if err != nil {
+		t.Fatal(err)
+	}
+	after, _ := newTestDeck(t, g, "Spanish", nil, "gato")
+
+	if _, err := b.Restore("backup-20260302-120000.000-9.db", testStart); !errors.Is(err, ErrBackupNotFound) {
+		t.Errorf("restoring a missing backup = %v, want ErrBackupNotFound", err)
+	}
+	undo, err := b.Restore(backup.Name, testStart.Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := g.GetDeckByID(before.ID); err != nil {
+		t.Errorf("deck from the backup is gone: %v", err)
+	}
+	var count int64
+	if err := g.DB.Model(&models.Deck{}).Where("id = ?", after.ID).Count(&count).Error; err != nil || count != 0 {
+		t.Errorf("deck made after the backup is still there: %d %v", count, err)
+	}
+	got := backupNames(t, b)
+	if len(got) != 2 || got[0] != undo.Name || got[1] != backup.Name {
+		t.Errorf("backups = %v, want the undo backup and the restored one", got)
+	}
+}
@@ -0,0 +1,93 @@
+package database
+
+import (
+	"time"
+	"webproject/models"
+	"webproject/spacedrepetition"
+)
+
+// GetCalibrationSamples collects reviews of due cards since the given time
+// (zero for all time), taking only the first answer per card per study day so
+// in-session retries after a lapse are left out. deckID 0 covers every deck.
+func (g *GormDB) GetCalibrationSamples(deckID uint, since time.Time, boundary DayBoundary) ([]spacedrepetition.CalibrationSample, error) {
+	var logs []models.ReviewLog
+	query := g.reviewLogs().Where("cram = ?", false).Order("card_id ASC, reviewed_at ASC, id ASC")
+	if deckID != 0 {
+		query = query.Where("deck_id = ?", deckID)
+	}
+	if err := query.Find(&logs).Error; err != nil {
+		return nil, err
+	}
+
+	var cards []models.Card
+	query = g.DB.Select("id", "card_created")
+	if deckID != 0 {
+		query = query.Where("deck_id = ?", deckID)
+	}
+	if err := query.Find(&cards).Error; err != nil {
+		return nil, err
+	}
+	created := map[uint]time.Time{}
+	for _, card := range cards {
+		created[card.ID] = card.CardCreated
+	}
+
+	weights := map[uint][]float64{}
+	weightsFor := func(deckID uint) ([]float64, error) {
+		if w, ok := weights[deckID]; ok {
+			return w, nil
+		}
+		options, err := g.GetDeckOptions(deckID)
+		if err != nil {
+			return nil, err
+		}
+		weights[deckID] = spacedrepetition.ValidFSRSWeights(options.FSRSWeights)
+		return weights[deckID], nil
+	}
+
+	var samples []spacedrepetition.CalibrationSample
+	lastDay := -1
+	for i, l := range logs {
+		firstOfCard := i == 0 || logs[i-1].CardID != l.CardID
+		if firstOfCard {
+			lastDay = -1
+			if _, ok := created[l.CardID]; !ok {
+				created[l.CardID] = l.ReviewedAt
+			}
+		}
+
+		day := boundary.dayNumber(l.ReviewedAt)
+		sameDay := day == lastDay
+		lastDay = day
+
+		if l.Stage != "review" || sameDay || firstOfCard || l.ReviewedAt.Before(since) {
+			continue
+		}
+
+		// logs from before the previous review time was recorded
+		lastReview := l.LastReviewBefore
+		if lastReview.IsZero() {
+			lastReview = logs[i-1].ReviewedAt
+		}
+
+		w, err := weightsFor(l.DeckID)
+		if err != nil {
+			return nil, err
+		}
+		stability := l.StabilityBefore
+		if stability == 0 {
+			stability = spacedrepetition.CardMemoryState(w, models.Card{Ease: l.EaseBefore}).Stability
+		}
+
+		elapsedDays := l.ReviewedAt.Sub(lastReview).Hours() / 24
+		samples = append(samples, spacedrepetition.CalibrationSample{
+			ElapsedDays: elapsedDays,
+			AgeDays:     l.ReviewedAt.Sub(created[l.CardID]).Hours() / 24,
+			Ease:        l.EaseBefore,
+			Predicted:   spacedrepetition.Retrievability(elapsedDays, stability),
+			Recalled:    l.Correct,
+		})
+	}
+
+	return samples, nil
+}
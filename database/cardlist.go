@@ -0,0 +1,107 @@
+package database
+
+import (
+	"reflect"
+	"webproject/models"
+
+	"gorm.io/gorm"
+)
+
+// CardSortColumns maps the sort names accepted by card listings to columns
+var CardSortColumns = map[string]string{
+	"created":  "card_created",
+	"due":      "review_due_date",
+	"ease":     "ease",
+	"lapses":   "lapses",
+	"question": "question COLLATE NOCASE",
+}
+
+// CardFields are the columns a listing can be narrowed down to, plus tags
+var CardFields = map[string]bool{
+	"id": true, "deck_id": true, "question": true, "answer": true, "extra": true,
+	"stage": true, "ease": true, "lapses": true, "correct": true, "incorrect": true,
+	"card_created": true, "last_review_date": true, "review_due_date": true,
+	"stability": true, "difficulty": true, "audio": true, "image": true, "tags": true,
+}
+
+type CardListOptions struct {
+	Tags   []string
+	Sort   string // a key of CardSortColumns, by id when empty
+	Desc   bool
+	Offset int
+	Limit  int
+}
+
+func (g *GormDB) cardListQuery(deckID uint, opts CardListOptions) (*gorm.DB, int64, error) {
+	query := g.cards().Scopes(withTags(opts.Tags)).Where("deck_id = ?", deckID)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	direction := " ASC"
+	if opts.Desc {
+		direction = " DESC"
+	}
+	// id breaks ties so pages don't overlap
+	if column, ok := CardSortColumns[opts.Sort]; ok {
+		query = query.Order(column + direction)
+	}
+	query = query.Order("id" + direction).Offset(opts.Offset).Limit(opts.Limit)
+	return query, total, nil
+}
+
+// ListCards returns one page of a deck's cards and the number of cards
+// across all pages
+func (g *GormDB) ListCards(deckID uint, opts CardListOptions) ([]models.Card, int64, error) {
+	query, total, err := g.cardListQuery(deckID, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var cards []models.Card
+	err = query.Preload("Tags").Find(&cards).Error
+	return cards, total, err
+}
+
+// ListCardFields is ListCards returning only the given CardFields, under
+// the same keys ListCards uses. The id is always included.
+func (g *GormDB) ListCardFields(deckID uint, opts CardListOptions, fields []string) ([]map[string]any, int64, error) {
+	query, total, err := g.cardListQuery(deckID, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	stmt := &gorm.Statement{DB: g.DB}
+	if err := stmt.Parse(&models.Card{}); err != nil {
+		return nil, 0, err
+	}
+	columns := []string{"id"}
+	keys := []string{"ID"}
+	for _, field := range fields {
+		switch field {
+		case "id":
+		case "tags":
+			query = query.Preload("Tags")
+			keys = append(keys, "Tags")
+		default:
+			columns = append(columns, field)
+			keys = append(keys, stmt.Schema.LookUpField(field).Name)
+		}
+	}
+
+	var cards []models.Card
+	if err := query.Select(columns).Find(&cards).Error; err != nil {
+		return nil, 0, err
+	}
+	rows := make([]map[string]any, len(cards))
+	for i, card := range cards {
+		value := reflect.ValueOf(card)
+		rows[i] = map[string]any{}
+		for _, key := range keys {
+			rows[i][key] = value.FieldByName(key).Interface()
+		}
+	}
+	return rows, total, nil
+}
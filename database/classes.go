@@ -0,0 +1,376 @@
+package database
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+	"webproject/models"
+
+	"gorm.io/gorm"
+)
+
+const mostMissedLimit = 10
+
+var (
+	ErrClassNotFound  = errors.New("class not found")
+	ErrNotDeckOwner   = errors.New("only decks you own can be added to a class")
+	ErrStudentMissing = errors.New("user is not a student of this class")
+)
+
+type ClassInfo struct {
+	ID        uint   `json:"id"`
+	Name      string `json:"name"`
+	TeacherID uint   `json:"teacher_id"`
+	Teacher   string `json:"teacher"`
+	JoinCode  string `json:"join_code"`
+	Role      string `json:"role"` // teacher or student
+	Students  int64  `json:"students"`
+}
+
+type ClassStudent struct {
+	UserID   uint      `json:"user_id"`
+	Username string    `json:"username"`
+	JoinedAt time.Time `json:"joined_at"`
+}
+
+type MissedCard struct {
+	CardID   uint   `json:"card_id"`
+	Question string `json:"question"`
+	Misses   int    `json:"misses"`
+	Students int    `json:"students,omitempty"` // how many students missed it, class-wide only
+}
+
+type StudentStats struct {
+	UserID       uint           `json:"user_id"`
+	Username     string         `json:"username"`
+	CardsLearned int64          `json:"cards_learned"`
+	DueBacklog   int64          `json:"due_backlog"`
+	Reviews      int            `json:"reviews"`
+	Retention    RetentionStats `json:"retention"`
+	LastStudied  *time.Time     `json:"last_studied"` // nil if never
+	MostMissed   []MissedCard   `json:"most_missed"`
+}
+
+type ClassDeckStats struct {
+	TotalCards int64          `json:"total_cards"`
+	Students   []StudentStats `json:"students"`
+	MostMissed []MissedCard   `json:"most_missed"`
+}
+
+func newJoinCode() (string, error) {
+	raw := make([]byte, 5)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return base32.StdEncoding.EncodeToString(raw), nil
+}
+
+func (g *GormDB) CreateClass(name string) (models.Class, error) {
+	code, err := newJoinCode()
+	if err != nil {
+		return models.Class{}, err
+	}
+	class := models.Class{Name: name, TeacherID: g.UserID, JoinCode: code, CreatedAt: g.Now()}
+	err = g.DB.Create(&class).Error
+	return class, err
+}
+
+// ClassRole is teacher or student, or empty when the user isn't in the class
+func (g *GormDB) ClassRole(classID uint) (string, error) {
+	var class models.Class
+	found := g.DB.Limit(1).Find(&class, classID)
+	if found.Error != nil || found.RowsAffected == 0 {
+		return "", found.Error
+	}
+	if class.TeacherID == g.UserID {
+		return "teacher", nil
+	}
+
+	var members int64
+	err := g.DB.Model(&models.ClassMember{}).
+		Where("class_id = ? AND user_id = ?", classID, g.UserID).
+		Count(&members).Error
+	if err != nil || members == 0 {
+		return "", err
+	}
+	return "student", nil
+}
+
+// GetClasses lists the classes the user teaches followed by the ones they
+// are a student of
+func (g *GormDB) GetClasses() ([]ClassInfo, error) {
+	classes := []ClassInfo{}
+	err := g.DB.Model(&models.Class{}).
+		Select(`classes.id, classes.name, classes.teacher_id, users.username AS teacher, classes.join_code,
+			CASE WHEN classes.teacher_id = ? THEN 'teacher' ELSE 'student' END AS role,
+			(SELECT COUNT(*) FROM class_members WHERE class_members.class_id = classes.id) AS students`, g.UserID).
+		Joins("LEFT JOIN users ON users.id = classes.teacher_id").
+		Where("classes.teacher_id = ? OR classes.id IN (SELECT class_id FROM class_members WHERE user_id = ?)",
+			g.UserID, g.UserID).
+		Order("role DESC, classes.name ASC, classes.id ASC").
+		Scan(&classes).Error
+	return classes, err
+}
+
+func (g *GormDB) GetClassByID(id uint) (models.Class, error) {
+	var class models.Class
+	err := g.DB.First(&class, id).Error
+	return class, err
+}
+
+// DeleteClass removes the class. Students keep their progress but lose
+// access to the class's decks.
+func (g *GormDB) DeleteClass(id uint) error {
+	return g.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("class_id = ?", id).Delete(&models.ClassMember{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("class_id = ?", id).Delete(&models.ClassDeck{}).Error; err != nil {
+			return err
+		}
+		assignments := tx.Model(&models.Assignment{}).Select("id").Where("class_id = ?", id)
+		if err := tx.Where("assignment_id IN (?)", assignments).Delete(&models.AssignmentCompletion{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("class_id = ?", id).Delete(&models.Assignment{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.Class{}, id).Error
+	})
+}
+
+// JoinClass adds the user as a student of the class with the code. Joining
+// twice is harmless.
+func (g *GormDB) JoinClass(code string) (models.Class, error) {
+	var class models.Class
+	err := g.DB.Where("join_code = ?", strings.ToUpper(strings.TrimSpace(code))).First(&class).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return class, ErrClassNotFound
+	}
+	if err != nil || class.TeacherID == g.UserID {
+		return class, err
+	}
+
+	member := models.ClassMember{ClassID: class.ID, UserID: g.UserID, CreatedAt: g.Now()}
+	err = g.DB.Where("class_id = ? AND user_id = ?", class.ID, g.UserID).FirstOrCreate(&member).Error
+	return class, err
+}
+
+func (g *GormDB) GetClassStudents(classID uint) ([]ClassStudent, error) {
+	students := []ClassStudent{}
+	err := g.DB.Model(&models.ClassMember{}).
+		Select("class_members.user_id, users.username, class_members.created_at AS joined_at").
+		Joins("JOIN users ON users.id = class_members.user_id").
+		Where("class_members.class_id = ?", classID).
+		Order("users.username ASC").
+		Scan(&students).Error
+	return students, err
+}
+
+func (g *GormDB) RemoveClassStudent(classID uint, userID uint) error {
+	result := g.DB.Where("class_id = ? AND user_id = ?", classID, userID).Delete(&models.ClassMember{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrStudentMissing
+	}
+	return nil
+}
+
+func (g *GormDB) GetClassDecks(classID uint) ([]models.Deck, error) {
+	decks := []models.Deck{}
+	err := g.DB.Where("id IN (SELECT deck_id FROM class_decks WHERE class_id = ?)", classID).
+		Order("name ASC, id ASC").
+		Find(&decks).Error
+	return decks, err
+}
+
+// AddClassDeck shares one of the user's decks with the class
+func (g *GormDB) AddClassDeck(classID uint, deckID uint) error {
+	role, err := g.DeckRole(deckID)
+	if err != nil {
+		return err
+	}
+	deck, err := g.GetDeckByID(deckID)
+	if err != nil {
+		return err
+	}
+	if role != "owner" || deck.Filter != "" {
+		return ErrNotDeckOwner
+	}
+	link := models.ClassDeck{ClassID: classID, DeckID: deckID}
+	return g.DB.Where("class_id = ? AND deck_id = ?", classID, deckID).FirstOrCreate(&link).Error
+}
+
+func (g *GormDB) RemoveClassDeck(classID uint, deckID uint) error {
+	return g.DB.Where("class_id = ? AND deck_id = ?", classID, deckID).Delete(&models.ClassDeck{}).Error
+}
+
+// GetClassDeckStats sums up how every student of the class does on a deck
+// and its subdecks. Retention and misses look back windowDays days and
+// leave out cram answers. Decks not shared with the class return
+// ErrDeckNotInClass.
+func (g *GormDB) GetClassDeckStats(classID uint, deckID uint, boundary DayBoundary, windowDays int) (ClassDeckStats, error) {
+	stats := ClassDeckStats{Students: []StudentStats{}, MostMissed: []MissedCard{}}
+	now := g.Now()
+	if err := g.checkClassDeck(classID, deckID); err != nil {
+		return stats, err
+	}
+
+	deckIDs, err := g.GetDeckSubtreeIDs(deckID)
+	if err != nil {
+		return stats, err
+	}
+	students, err := g.GetClassStudents(classID)
+	if err != nil {
+		return stats, err
+	}
+	if err := g.DB.Model(&models.Card{}).Where("deck_id IN ?", deckIDs).Count(&stats.TotalCards).Error; err != nil {
+		return stats, err
+	}
+	if len(students) == 0 {
+		return stats, nil
+	}
+	userIDs := make([]uint, len(students))
+	for i, s := range students {
+		userIDs[i] = s.UserID
+	}
+
+	// progress rows only exist once a student has answered a card
+	progressCounts := func(where string, args ...any) (map[uint]int64, error) {
+		var rows []struct {
+			UserID uint
+			Count  int64
+		}
+		err := g.DB.Model(&models.CardProgress{}).
+			Select("card_progresses.user_id, COUNT(*) AS count").
+			Joins("JOIN cards ON cards.id = card_progresses.card_id AND cards.deleted_at IS NULL").
+			Where("cards.deck_id IN ? AND card_progresses.user_id IN ?", deckIDs, userIDs).
+			Where(where, args...).
+			Group("card_progresses.user_id").
+			Scan(&rows).Error
+		counts := map[uint]int64{}
+		for _, row := range rows {
+			counts[row.UserID] = row.Count
+		}
+		return counts, err
+	}
+	learned, err := progressCounts("card_progresses.stage = ?", "review")
+	if err != nil {
+		return stats, err
+	}
+	due, err := progressCounts("card_progresses.stage = ? AND card_progresses.review_due_date <= ?", "review", now)
+	if err != nil {
+		return stats, err
+	}
+
+	var logs []models.ReviewLog
+	err = g.DB.Where("user_id IN ? AND deck_id IN ? AND reviewed_at >= ? AND cram = ?",
+		userIDs, deckIDs, now.AddDate(0, 0, -windowDays), false).
+		Order("reviewed_at ASC, id ASC").
+		Find(&logs).Error
+	if err != nil {
+		return stats, err
+	}
+	logsByUser := map[uint][]models.ReviewLog{}
+	for _, l := range logs {
+		logsByUser[l.UserID] = append(logsByUser[l.UserID], l)
+	}
+
+	classMisses := map[uint]*MissedCard{}
+	for _, student := range students {
+		s := StudentStats{
+			UserID:       student.UserID,
+			Username:     student.Username,
+			CardsLearned: learned[student.UserID],
+			DueBacklog:   due[student.UserID],
+		}
+		studentLogs := logsByUser[student.UserID]
+		s.Reviews = len(studentLogs)
+		s.Retention = trueRetention(studentLogs, boundary)
+		s.Retention.Days = windowDays
+
+		misses := map[uint]*MissedCard{}
+		for _, l := range studentLogs {
+			reviewedAt := l.ReviewedAt
+			s.LastStudied = &reviewedAt
+			if l.Correct {
+				continue
+			}
+			if misses[l.CardID] == nil {
+				misses[l.CardID] = &MissedCard{CardID: l.CardID}
+			}
+			misses[l.CardID].Misses++
+		}
+		for id, m := range misses {
+			if classMisses[id] == nil {
+				classMisses[id] = &MissedCard{CardID: id}
+			}
+			classMisses[id].Misses += m.Misses
+			classMisses[id].Students++
+		}
+		s.MostMissed = topMissed(misses)
+		stats.Students = append(stats.Students, s)
+	}
+	stats.MostMissed = topMissed(classMisses)
+
+	// fill in the questions of every card that made a top list
+	ids := map[uint]bool{}
+	for _, m := range stats.MostMissed {
+		ids[m.CardID] = true
+	}
+	for _, s := range stats.Students {
+		for _, m := range s.MostMissed {
+			ids[m.CardID] = true
+		}
+	}
+	if len(ids) == 0 {
+		return stats, nil
+	}
+	cardIDs := make([]uint, 0, len(ids))
+	for id := range ids {
+		cardIDs = append(cardIDs, id)
+	}
+	var cards []models.Card
+	if err := g.DB.Select("id", "question").Where("id IN ?", cardIDs).Find(&cards).Error; err != nil {
+		return stats, err
+	}
+	questions := map[uint]string{}
+	for _, card := range cards {
+		questions[card.ID] = card.Question
+	}
+	for i := range stats.MostMissed {
+		stats.MostMissed[i].Question = questions[stats.MostMissed[i].CardID]
+	}
+	for _, s := range stats.Students {
+		for i := range s.MostMissed {
+			s.MostMissed[i].Question = questions[s.MostMissed[i].CardID]
+		}
+	}
+	return stats, nil
+}
+
+// topMissed orders by misses, then by how many students missed the card
+func topMissed(misses map[uint]*MissedCard) []MissedCard {
+	top := make([]MissedCard, 0, len(misses))
+	for _, m := range misses {
+		top = append(top, *m)
+	}
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Misses != top[j].Misses {
+			return top[i].Misses > top[j].Misses
+		}
+		if top[i].Students != top[j].Students {
+			return top[i].Students > top[j].Students
+		}
+		return top[i].CardID < top[j].CardID
+	})
+	if len(top) > mostMissedLimit {
+		top = top[:mostMissedLimit]
+	}
+	return top
+}
@@ -0,0 +1,30 @@
+package database
+
+import (
+	"errors"
+	"testing"
+	"webproject/models"
+)
+
+func TestClassDeckStatsOnlyCoverTheClassesDecks(t *testing.T) {
+	alice, bob, _, card, assignment := newTestAssignment(t)
+	// shared with bob on his own, not through the class
+	private, privateCards := newTestDeck(t, alice, "Diary", nil, "secret")
+	if err := alice.DB.Create(&models.DeckMember{DeckID: private.ID, UserID: bob.UserID, Role: "viewer"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	learn(t, bob, card)
+	learn(t, bob, privateCards[0])
+
+	boundary := DayBoundary{Location: testStart.Location()}
+	if _, err := alice.GetClassDeckStats(assignment.ClassID, private.ID, boundary, 30); !errors.Is(err, ErrDeckNotInClass) {
+		t.Errorf("private deck: got %v, want ErrDeckNotInClass", err)
+	}
+	stats, err := alice.GetClassDeckStats(assignment.ClassID, card.DeckID, boundary, 30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats.Students) != 1 || stats.Students[0].CardsLearned != 1 {
+		t.Errorf("class deck stats = %+v, want bob with one card learned", stats.Students)
+	}
+}
@@ -0,0 +1,45 @@
+package database
+
+import (
+	"time"
+	"webproject/models"
+)
+
+// GetCramCards picks cards from a deck and its subdecks in random order,
+// whatever their stage or due date
+func (g *GormDB) GetCramCards(deckID uint, limit int, tags ...string) ([]models.Card, error) {
+	ids, err := g.GetDeckSubtreeIDs(deckID)
+	if err != nil {
+		return nil, err
+	}
+
+	var cards []models.Card
+	err = g.cards().
+		Scopes(withTags(tags)).
+		Where("deck_id IN ?", ids).
+		Order("RANDOM()").
+		Limit(limit).
+		Find(&cards).Error
+	return cards, err
+}
+
+// CramAnswerCardByID records the answer in the log flagged as cram and
+// leaves the card's scheduling alone
+func (g *GormDB) CramAnswerCardByID(id uint, answer string, correct bool, duration time.Duration) error {
+	card, err := g.GetCardByID(id)
+	if err != nil {
+		return err
+	}
+	return g.logCramAnswer(card, card, answer, correct, duration)
+}
+
+func (g *GormDB) logCramAnswer(before models.Card, after models.Card, answer string, correct bool, duration time.Duration) error {
+	options, err := g.GetDeckOptions(homeDeckID(before))
+	if err != nil {
+		return err
+	}
+
+	event := newAnswerEvent(answer, correct, duration, options, g.Now())
+	event.cram = true
+	return g.saveAnsweredCard(before, after, event)
+}
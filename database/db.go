@@ -3,19 +3,40 @@ package database
 import (
 	"math/rand/v2"
 	"time"
+	"webproject/clock"
 	"webproject/models"
 	"webproject/spacedrepetition"
 
 	"gorm.io/gorm"
 )
 
+// GormDB reads and writes as one user, whose progress, review log and
+// preferences it sees. User 0 is the single learner from before accounts,
+// which the command line tools and simulations still use.
 type GormDB struct {
-	DB *gorm.DB
+	DB     *gorm.DB
+	Clock  clock.Clock
+	UserID uint
+}
+
+// ForUser returns a copy of g working as the given user
+func (g *GormDB) ForUser(userID uint) *GormDB {
+	user := *g
+	user.UserID = userID
+	return &user
+}
+
+func (g *GormDB) Now() time.Time {
+	if g.Clock == nil {
+		return time.Now().UTC()
+	}
+	return g.Clock.Now().UTC()
 }
 
 func (g *GormDB) CreateDeck(name string) error {
 	var deck models.Deck
 	deck.Name = name
+	deck.OwnerID = g.UserID
 	return g.DB.Create(&deck).Error
 }
 
@@ -25,19 +46,26 @@ func (g *GormDB) CreateCard(card models.Card) error {
 
 func (g *GormDB) GetCardByID(id uint) (models.Card, error) {
 	var card models.Card
-	err := g.DB.First(&card, id).Error
+	err := g.cards().First(&card, id).Error
 	return card, err
 }
 
-func (g *GormDB) GetAllCardsByDeckID(id uint) ([]models.Card, error) {
+// tags narrows the cards down to those carrying all of them
+func (g *GormDB) GetAllCardsByDeckID(id uint, tags ...string) ([]models.Card, error) {
 	var cards []models.Card
-	err := g.DB.Where("deck_id = ?", id).Find(&cards).Error
+	err := g.cards().Preload("Tags").Scopes(withTags(tags)).Where("deck_id = ?", id).Find(&cards).Error
 	return cards, err
 }
 
+// Wrong answers come from the card's own deck when it is known, so studying
+// a parent deck doesn't mix in answers from sibling subdecks
 func (g *GormDB) GetShuffledChoicesForCard(deckID uint, mostDueCard models.Card) ([]models.Card, error) {
+	if mostDueCard.DeckID != 0 {
+		deckID = mostDueCard.DeckID
+	}
+
 	var count int64
-	cardCountError := g.DB.Model(&models.Card{}).Where("deck_id = ?", deckID).Count(&count).Error
+	cardCountError := g.cards().Where("deck_id = ?", deckID).Count(&count).Error
 	if cardCountError != nil {
 		return nil, cardCountError
 	}
@@ -53,7 +81,7 @@ func (g *GormDB) GetShuffledChoicesForCard(deckID uint, mostDueCard models.Card)
 	}
 
 	var falseAnswers []models.Card
-	err := g.DB.Where("deck_id = ? AND id != ?", deckID, mostDueCard.ID).
+	err := g.cards().Where("deck_id = ? AND id != ?", deckID, mostDueCard.ID).
 		Order("RANDOM()").
 		Limit(limit).
 		Find(&falseAnswers).Error
@@ -71,62 +99,118 @@ func (g *GormDB) GetShuffledChoicesForCard(deckID uint, mostDueCard models.Card)
 
 func (g *GormDB) GetLearningCardsByDeckID(id uint) ([]models.Card, error) {
 	var cards []models.Card
-	err := g.DB.Where("deck_id = ? AND stage = ?", id, "learning").Find(&cards).Error
+	err := g.cards().Where("deck_id = ? AND stage = ?", id, "learning").Find(&cards).Error
 	return cards, err
 }
 func (g *GormDB) GetReviewCardsByDeckID(id uint) ([]models.Card, error) {
 	var cards []models.Card
-	err := g.DB.Where("deck_id = ? AND stage = ?", id, "review").Find(&cards).Error
+	err := g.cards().Where("deck_id = ? AND stage = ?", id, "review").Find(&cards).Error
 	return cards, err
 }
 func (g *GormDB) GetDueReviewCardsByDeckID(id uint) ([]models.Card, error) {
-	now := time.Now().UTC()
+	now := g.Now()
 
 	var cards []models.Card
-	err := g.DB.Where("deck_id = ? AND stage = ? AND review_due_date <= ?", id, "review", now).Find(&cards).Error
+	err := g.cards().Where("deck_id = ? AND stage = ? AND review_due_date <= ?", id, "review", now).Find(&cards).Error
 	return cards, err
 }
 
-func (g *GormDB) GetFirstXCards(deckID uint, limit int, cardStage string) ([]models.Card, error) {
+func (g *GormDB) GetFirstXCards(deckID uint, limit int, cardStage string, tags ...string) ([]models.Card, error) {
 	var cards []models.Card
-	err := g.DB.
-		Where("deck_id = ? AND stage = ? AND review_due_date <= ?", deckID, cardStage, time.Now().UTC()).
+	err := g.cards().
+		Scopes(withTags(tags)).
+		Where("deck_id = ? AND stage = ? AND review_due_date <= ?", deckID, cardStage, g.Now()).
 		Order("review_due_date ASC").
 		Limit(limit).
 		Find(&cards).Error
 	return cards, err
 }
 
+// GetDeckByID only finds decks the user can open
 func (g *GormDB) GetDeckByID(id uint) (models.Deck, error) {
 	var deck models.Deck
-	err := g.DB.First(&deck, id).Error
+	err := g.DB.Scopes(g.visibleDecks).First(&deck, id).Error
 	return deck, err
 }
 
 func (g *GormDB) SelectAllDecks() ([]models.Deck, error) {
 	var decks []models.Deck
-	err := g.DB.Find(&decks).Error
+	err := g.DB.Scopes(g.visibleDecks).Find(&decks).Error
 	return decks, err
 }
 
-func (g *GormDB) UpdateLearningCardByID(id uint, correct bool) (models.Card, error) { // Return updated card
+// duration is how long the learner took to answer, 0 if unknown
+func (g *GormDB) UpdateLearningCardByID(id uint, answer string, correct bool, duration time.Duration) (models.Card, error) { // Return updated card
 	card, err := g.GetCardByID(id)
 	if err != nil {
 		return models.Card{}, err
 	}
+	reschedule, err := g.reschedules(card)
+	if err != nil {
+		return models.Card{}, err
+	}
+	if !reschedule {
+		return g.answerWithoutRescheduling(card, answer, correct, duration)
+	}
+
+	options, err := g.GetDeckOptions(homeDeckID(card))
+	if err != nil {
+		return models.Card{}, err
+	}
+	event := newAnswerEvent(answer, correct, duration, options, g.Now())
+	before := card
+	card = scheduleLearning(card, event, options)
+
+	err = g.saveAnsweredCard(before, card, event)
+	return card, err
+}
+
+func (g *GormDB) UpdateReviewCardByID(id uint, answer string, correct bool, duration time.Duration) error {
+	card, err := g.GetCardByID(id)
+	if err != nil {
+		return err
+	}
+	reschedule, err := g.reschedules(card)
+	if err != nil {
+		return err
+	}
+	if !reschedule {
+		_, err = g.answerWithoutRescheduling(card, answer, correct, duration)
+		return err
+	}
+
+	options, err := g.GetDeckOptions(homeDeckID(card))
+	if err != nil {
+		return err
+	}
+	event := newAnswerEvent(answer, correct, duration, options, g.Now())
+	before := card
+	card = scheduleReview(card, event, options)
+
+	return g.saveAnsweredCard(before, card, event)
+}
 
-	now := time.Now().UTC()
+// scheduleLearning answers a card in the learning stage
+func scheduleLearning(card models.Card, event answerEvent, options models.DeckOptions) models.Card {
+	weights := spacedrepetition.ValidFSRSWeights(options.FSRSWeights)
+	now := event.at
 	shortDelay := now.Add(1 * time.Minute)
-	initialReviewDelay := now.Add(4 * time.Hour)
 
 	card.LastReviewDate = now
 
-	if correct {
+	// only the first answer sets the memory state, repeated learning steps are
+	// short-term practice
+	if card.Stability == 0 {
+		state := spacedrepetition.InitialMemoryState(weights, event.grade)
+		card.Stability, card.Difficulty = state.Stability, state.Difficulty
+	}
+
+	if event.correct {
 		card.Correct++
 		if card.Ease > 1 { // Condition for graduating to "review"
 			card.Ease = uint(spacedrepetition.GetNextEaseLevel(int(card.Ease), 1))
 			card.Stage = "review"
-			card.ReviewDueDate = initialReviewDelay
+			card.ReviewDueDate = now.Add(spacedrepetition.NextReviewInterval(card.Stability, options.DesiredRetention))
 		} else {
 			card.Ease = uint(spacedrepetition.GetNextEaseLevel(int(card.Ease), 2))
 			card.ReviewDueDate = shortDelay
@@ -136,22 +220,26 @@ func (g *GormDB) UpdateLearningCardByID(id uint, correct bool) (models.Card, err
 		card.Ease = 1
 		card.ReviewDueDate = shortDelay
 	}
-
-	err = g.DB.Save(&card).Error
-	return card, err
+	return card
 }
 
-func (g *GormDB) UpdateReviewCardByID(id uint, correct bool) error {
-	card, _ := g.GetCardByID(id)
-	now := time.Now().UTC()
+// scheduleReview answers a card in the review stage
+func scheduleReview(card models.Card, event answerEvent, options models.DeckOptions) models.Card {
+	weights := spacedrepetition.ValidFSRSWeights(options.FSRSWeights)
+	now := event.at
 	shortDelay := now.Add(1 * time.Minute)
 
+	elapsedDays := now.Sub(card.LastReviewDate).Hours() / 24
+	state := spacedrepetition.NextMemoryState(weights, spacedrepetition.CardMemoryState(weights, card),
+		elapsedDays, event.grade)
+	card.Stability, card.Difficulty = state.Stability, state.Difficulty
+
 	card.LastReviewDate = now
 
-	if correct {
+	if event.correct {
 		card.Correct++
 		card.Ease = uint(spacedrepetition.GetNextEaseLevel(int(card.Ease), 2))
-		card.ReviewDueDate = spacedrepetition.CreateNextReviewDueDate(int(card.Ease))
+		card.ReviewDueDate = now.Add(spacedrepetition.NextReviewInterval(card.Stability, options.DesiredRetention))
 	} else {
 		card.Incorrect++
 		card.ReviewDueDate = shortDelay
@@ -160,33 +248,113 @@ func (g *GormDB) UpdateReviewCardByID(id uint, correct bool) error {
 			card.Ease = 1
 		}
 	}
+	return card
+}
 
-	return g.DB.Save(&card).Error
+// Cards borrowed by a filtered deck go home once they reach review and are
+// answered correctly
+func (g *GormDB) saveAnsweredCard(before models.Card, after models.Card, event answerEvent) error {
+	if event.correct && after.Stage == "review" && !event.cram {
+		returnHome(&after)
+	}
+	return g.DB.Transaction(func(tx *gorm.DB) error {
+		if err := g.saveProgress(tx, after); err != nil {
+			return err
+		}
+		log := newReviewLog(before, after, event)
+		log.UserID = g.UserID
+		if err := tx.Create(log).Error; err != nil {
+			return err
+		}
+		if event.cram || !event.correct {
+			return nil
+		}
+		// in the same transaction, so a failure doesn't leave the answer
+		// saved for the client to send again
+		t := *g
+		t.DB = tx
+		return t.recordCompletions(after.ID, event.at)
+	})
 }
 
+// UpdateCardByID changes the card's content. Cards the user can't edit are
+// not found.
 func (g *GormDB) UpdateCardByID(id uint, question string, answer string, extra string) error {
-	card, _ := g.GetCardByID(id)
-	card.Question = question
-	card.Answer = answer
-	card.Extra = extra
-
-	return g.DB.Save(&card).Error
+	updated := g.DB.Model(&models.Card{ID: id}).
+		Where("deck_id IN (?)", g.decksWithRole("editor")).
+		Updates(map[string]any{
+			"question": question,
+			"answer":   answer,
+			"extra":    extra,
+		})
+	if updated.Error == nil && updated.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return updated.Error
 }
 
+// DeleteCardByID moves the card to the trash. The user's progress, tags and
+// review log stay with it in case it is restored. Cards the user can't edit
+// are not found.
 func (g *GormDB) DeleteCardByID(id uint) error {
 	card, err := g.GetCardByID(id)
 	if err != nil {
 		return err
 	}
+	if role, err := g.DeckRole(homeDeckID(card)); err != nil {
+		return err
+	} else if !RoleAllows(role, "editor") {
+		return gorm.ErrRecordNotFound
+	}
 
-	return g.DB.Delete(card).Error
+	now := g.Now()
+	return g.DB.Transaction(func(tx *gorm.DB) error {
+		if err := recordDeletions(tx, homeDeckID(card), []uint{card.ID}, now); err != nil {
+			return err
+		}
+		return tx.Model(&models.Card{ID: card.ID}).UpdateColumn("deleted_at", now).Error
+	})
 }
 
+// DeleteDeckByID moves the deck and its cards to the trash. Subdecks of a
+// deleted deck move up to its parent. Only the deck's owners find it.
 func (g *GormDB) DeleteDeckByID(id uint) error {
-	card, err := g.GetDeckByID(id)
+	deck, err := g.GetDeckByID(id)
 	if err != nil {
 		return err
 	}
+	if role, err := g.DeckRole(id); err != nil {
+		return err
+	} else if role != "owner" {
+		return gorm.ErrRecordNotFound
+	}
 
-	return g.DB.Delete(card).Error
+	now := g.Now()
+	return g.DB.Transaction(func(tx *gorm.DB) error {
+		// borrowed cards go home instead of being deleted with a filtered
+		// deck, and cards of a normal deck are deleted even while borrowed
+		if err := emptyFilteredDeck(tx, deck.ID); err != nil {
+			return err
+		}
+		var deleted []uint
+		if err := tx.Model(&models.Card{}).Where("deck_id = ?", deck.ID).Pluck("id", &deleted).Error; err != nil {
+			return err
+		}
+		if err := recordDeletions(tx, deck.ID, deleted, now); err != nil {
+			return err
+		}
+		// cards already in the trash keep their own time, so restoring the
+		// deck only brings back the cards deleted with it
+		err := tx.Model(&models.Card{}).Where("deck_id = ?", deck.ID).UpdateColumn("deleted_at", now).Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.Model(&models.Deck{}).Where("parent_id = ?", deck.ID).
+			Update("parent_id", deck.ParentID).Error
+		if err != nil {
+			return err
+		}
+		return tx.Model(&deck).UpdateColumn("deleted_at", now).Error
+	})
 }
@@ -0,0 +1,107 @@
+package database
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+	"webproject/clock"
+	"webproject/models"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+var testStart = time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+
+// newTestDB is an empty migrated database on a fake clock, seen as user 1
+func newTestDB(t *testing.T) (*GormDB, *clock.Fake) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")),
+		&gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+	fake := clock.NewFake(testStart)
+	return &GormDB{DB: db, Clock: fake, UserID: 1}, fake
+}
+
+// newTestDeck creates a deck owned by the user with one card per question
+func newTestDeck(t *testing.T, g *GormDB, name string, parentID *uint, questions ...string) (models.Deck, []models.Card) {
+	t.Helper()
+	deck := models.Deck{Name: name, ParentID: parentID, OwnerID: g.UserID}
+	if err := g.DB.Create(&deck).Error; err != nil {
+		t.Fatal(err)
+	}
+	cards := make([]models.Card, len(questions))
+	for i, question := range questions {
+		cards[i] = models.Card{DeckID: deck.ID, Question: question, Answer: question + " answer",
+			CardCreated: g.Now(), UpdatedAt: g.Now()}
+		if err := g.DB.Create(&cards[i]).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	return deck, cards
+}
+
+func TestOtherUsersDecksAreNotFound(t *testing.T) {
+	alice, _ := newTestDB(t)
+	bob := alice.ForUser(2)
+	deck, cards := newTestDeck(t, alice, "French", nil, "chat")
+	card := cards[0]
+
+	tests := []struct {
+		name string
+		run  func() error
+	}{
+		{"GetDeckByID", func() error { _, err := bob.GetDeckByID(deck.ID); return err }},
+		{"GetCardByID", func() error { _, err := bob.GetCardByID(card.ID); return err }},
+		{"UpdateCardByID", func() error { return bob.UpdateCardByID(card.ID, "hijacked", "x", "") }},
+		{"DeleteCardByID", func() error { return bob.DeleteCardByID(card.ID) }},
+		{"DeleteDeckByID", func() error { return bob.DeleteDeckByID(deck.ID) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.run(); !errors.Is(err, gorm.ErrRecordNotFound) {
+				t.Fatalf("got %v, want record not found", err)
+			}
+		})
+	}
+
+	got, err := alice.GetCardByID(card.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Question != "chat" {
+		t.Errorf("question = %q, bob's edit went through", got.Question)
+	}
+	if _, err := alice.GetDeckByID(deck.ID); err != nil {
+		t.Errorf("alice lost her deck: %v", err)
+	}
+}
+
+func TestViewersCantChangeCards(t *testing.T) {
+	alice, _ := newTestDB(t)
+	bob := alice.ForUser(2)
+	deck, cards := newTestDeck(t, alice, "French", nil, "chat")
+	if err := alice.DB.Create(&models.DeckMember{DeckID: deck.ID, UserID: bob.UserID, Role: "viewer"}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := bob.GetCardByID(cards[0].ID); err != nil {
+		t.Fatalf("viewer can't read the card: %v", err)
+	}
+	if err := bob.UpdateCardByID(cards[0].ID, "hijacked", "x", ""); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("UpdateCardByID = %v, want record not found", err)
+	}
+	if err := bob.DeleteCardByID(cards[0].ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("DeleteCardByID = %v, want record not found", err)
+	}
+	if err := bob.DeleteDeckByID(deck.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("DeleteDeckByID = %v, want record not found", err)
+	}
+}
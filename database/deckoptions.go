@@ -0,0 +1,22 @@
+package database
+
+import (
+	"webproject/models"
+	"webproject/spacedrepetition"
+)
+
+// Options are the user's own. Decks without saved options get a zero value,
+// which means defaults.
+func (g *GormDB) GetDeckOptions(deckID uint) (models.DeckOptions, error) {
+	options := models.DeckOptions{UserID: g.UserID, DeckID: deckID}
+	err := g.DB.Where("user_id = ? AND deck_id = ?", g.UserID, deckID).FirstOrInit(&options).Error
+	if options.DesiredRetention == 0 {
+		options.DesiredRetention = spacedrepetition.DefaultDesiredRetention
+	}
+	return options, err
+}
+
+func (g *GormDB) SaveDeckOptions(options models.DeckOptions) error {
+	options.UserID = g.UserID
+	return g.DB.Save(&options).Error
+}
@@ -0,0 +1,219 @@
+package database
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"webproject/models"
+
+	"gorm.io/gorm"
+)
+
+// Deck paths use Anki's separator, "Spanish::Verbs::Irregular"
+const DeckPathSeparator = "::"
+
+type DeckNode struct {
+	ID       uint       `json:"id"`
+	Name     string     `json:"name"`
+	FullName string     `json:"full_name"`
+	ParentID *uint      `json:"parent_id"`
+	Children []DeckNode `json:"children"`
+}
+
+var ErrDeckExists = errors.New("a deck with this name already exists here")
+
+// CreateDeckPath creates every missing deck along path below parentID and
+// returns the last one. Existing parent decks along the way are reused if the
+// user can edit them, the last deck has to be new.
+func (g *GormDB) CreateDeckPath(path string, parentID *uint) (models.Deck, error) {
+	var deck models.Deck
+	err := g.DB.Transaction(func(tx *gorm.DB) error {
+		names := strings.Split(path, DeckPathSeparator)
+		for i, name := range names {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				return errors.New("deck names in a path can't be empty")
+			}
+
+			deck = models.Deck{}
+			query := tx.Scopes(g.editableDecks).Where("name = ?", name)
+			if parentID == nil {
+				// top-level decks shared with the user belong to someone else
+				query = query.Where("parent_id IS NULL AND owner_id = ?", g.UserID)
+			} else {
+				query = query.Where("parent_id = ?", *parentID)
+			}
+			found := query.Limit(1).Find(&deck)
+			if found.Error != nil {
+				return found.Error
+			}
+			if found.RowsAffected > 0 && i == len(names)-1 {
+				return ErrDeckExists
+			}
+			if found.RowsAffected == 0 {
+				deck = models.Deck{Name: name, ParentID: parentID, OwnerID: g.UserID}
+				if err := tx.Create(&deck).Error; err != nil {
+					return err
+				}
+			}
+			id := deck.ID
+			parentID = &id
+		}
+		return nil
+	})
+	return deck, err
+}
+
+func (g *GormDB) deckParents() (map[uint]*uint, error) {
+	var decks []models.Deck
+	if err := g.DB.Select("id", "parent_id").Find(&decks).Error; err != nil {
+		return nil, err
+	}
+	parents := make(map[uint]*uint, len(decks))
+	for _, deck := range decks {
+		parents[deck.ID] = deck.ParentID
+	}
+	return parents, nil
+}
+
+// GetDeckSubtreeIDs returns the deck itself followed by all its descendants
+func (g *GormDB) GetDeckSubtreeIDs(id uint) ([]uint, error) {
+	parents, err := g.deckParents()
+	if err != nil {
+		return nil, err
+	}
+
+	children := map[uint][]uint{}
+	for child, parent := range parents {
+		if parent != nil {
+			children[*parent] = append(children[*parent], child)
+		}
+	}
+
+	ids := []uint{id}
+	for i := 0; i < len(ids); i++ {
+		next := children[ids[i]]
+		sort.Slice(next, func(a, b int) bool { return next[a] < next[b] })
+		ids = append(ids, next...)
+	}
+	return ids, nil
+}
+
+func (g *GormDB) MoveDeck(id uint, parentID *uint) error {
+	if parentID != nil {
+		if *parentID == id {
+			return errors.New("a deck can't be its own parent")
+		}
+		parent, err := g.GetDeckByID(*parentID)
+		if err != nil {
+			return err
+		}
+		if parent.Filter != "" {
+			return errors.New("filtered decks can't have subdecks")
+		}
+		subtree, err := g.GetDeckSubtreeIDs(id)
+		if err != nil {
+			return err
+		}
+		for _, descendant := range subtree {
+			if descendant == *parentID {
+				return errors.New("a deck can't be moved into its own subdeck")
+			}
+		}
+	}
+
+	return g.DB.Model(&models.Deck{}).Where("id = ?", id).Update("parent_id", parentID).Error
+}
+
+// GetDeckTree nests the decks the user can open. A subdeck shared without
+// its parent is a root of the user's tree.
+func (g *GormDB) GetDeckTree() ([]DeckNode, error) {
+	var decks []models.Deck
+	if err := g.DB.Scopes(g.visibleDecks).Order("name ASC, id ASC").Find(&decks).Error; err != nil {
+		return nil, err
+	}
+	visible := map[uint]bool{}
+	for _, deck := range decks {
+		visible[deck.ID] = true
+	}
+
+	children := map[uint][]models.Deck{}
+	var roots []models.Deck
+	for _, deck := range decks {
+		if deck.ParentID == nil || !visible[*deck.ParentID] {
+			deck.ParentID = nil
+			roots = append(roots, deck)
+		} else {
+			children[*deck.ParentID] = append(children[*deck.ParentID], deck)
+		}
+	}
+
+	var build func(deck models.Deck, prefix string) DeckNode
+	build = func(deck models.Deck, prefix string) DeckNode {
+		node := DeckNode{
+			ID:       deck.ID,
+			Name:     deck.Name,
+			FullName: prefix + deck.Name,
+			ParentID: deck.ParentID,
+			Children: []DeckNode{},
+		}
+		for _, child := range children[deck.ID] {
+			node.Children = append(node.Children, build(child, node.FullName+DeckPathSeparator))
+		}
+		return node
+	}
+
+	tree := []DeckNode{}
+	for _, root := range roots {
+		tree = append(tree, build(root, ""))
+	}
+	return tree, nil
+}
+
+// GetFirstXCardsInTree gathers due cards from a deck and all its subdecks.
+// Each deck contributes at most its own session limit, and the whole session
+// is capped at limit.
+func (g *GormDB) GetFirstXCardsInTree(deckID uint, limit int, cardStage string, tags ...string) ([]models.Card, error) {
+	deck, err := g.GetDeckByID(deckID)
+	if err != nil {
+		return nil, err
+	}
+	if deck.Filter != "" {
+		return g.getFilteredDeckCards(deckID, limit, cardStage, tags...)
+	}
+
+	ids, err := g.GetDeckSubtreeIDs(deckID)
+	if err != nil {
+		return nil, err
+	}
+
+	var cards []models.Card
+	for _, id := range ids {
+		options, err := g.GetDeckOptions(id)
+		if err != nil {
+			return nil, err
+		}
+		deckLimit := limit
+		ownLimit := options.ReviewLimit
+		if cardStage == "learning" {
+			ownLimit = options.LearningLimit
+		}
+		if ownLimit > 0 {
+			deckLimit = min(deckLimit, ownLimit)
+		}
+
+		deckCards, err := g.GetFirstXCards(id, deckLimit, cardStage, tags...)
+		if err != nil {
+			return nil, err
+		}
+		cards = append(cards, deckCards...)
+	}
+
+	sort.SliceStable(cards, func(i, j int) bool {
+		return cards[i].ReviewDueDate.Before(cards[j].ReviewDueDate)
+	})
+	if len(cards) > limit {
+		cards = cards[:limit]
+	}
+	return cards, nil
+}
@@ -0,0 +1,43 @@
+package database
+
+import (
+	"testing"
+	"webproject/models"
+)
+
+func TestDeckTreeShowsSubdecksSharedWithoutTheirParent(t *testing.T) {
+	alice, _ := newTestDB(t)
+	bob := alice.ForUser(2)
+	spanish, _ := newTestDeck(t, alice, "Spanish", nil)
+	verbs, _ := newTestDeck(t, alice, "Verbs", &spanish.ID)
+	newTestDeck(t, alice, "Irregular", &verbs.ID)
+	if err := alice.DB.Create(&models.DeckMember{DeckID: verbs.ID, UserID: bob.UserID, Role: "viewer"}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	decks, err := bob.SelectAllDecks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree, err := bob.GetDeckTree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(decks) != 2 {
+		t.Fatalf("bob sees %d decks, want Verbs and Irregular", len(decks))
+	}
+	if len(tree) != 1 || tree[0].ID != verbs.ID || tree[0].ParentID != nil {
+		t.Fatalf("tree = %+v, want Verbs as the only root", tree)
+	}
+	if len(tree[0].Children) != 1 || tree[0].Children[0].FullName != "Verbs::Irregular" {
+		t.Errorf("children = %+v, want Verbs::Irregular", tree[0].Children)
+	}
+
+	tree, err = alice.GetDeckTree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tree) != 1 || tree[0].ID != spanish.ID || tree[0].Children[0].Children[0].FullName != "Spanish::Verbs::Irregular" {
+		t.Errorf("alice's tree = %+v, want Spanish::Verbs::Irregular", tree)
+	}
+}
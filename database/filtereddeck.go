@@ -0,0 +1,144 @@
+package database
+
+import (
+	"errors"
+	"time"
+	"webproject/models"
+	"webproject/search"
+
+	"gorm.io/gorm"
+)
+
+const DefaultFilterLimit = 100
+
+var ErrNotFilteredDeck = errors.New("deck is not a filtered deck")
+
+// homeDeckID is the deck a card belongs to, even while a filtered deck
+// borrows it
+func homeDeckID(card models.Card) uint {
+	if card.HomeDeckID != nil {
+		return *card.HomeDeckID
+	}
+	return card.DeckID
+}
+
+// CreateFilteredDeck creates a top-level deck and fills it with up to limit
+// cards matching query, most overdue first. It returns how many cards were
+// pulled in.
+func (g *GormDB) CreateFilteredDeck(name string, query string, limit int, reschedule bool) (models.Deck, int64, error) {
+	deck := models.Deck{Name: name, OwnerID: g.UserID, Filter: query, FilterLimit: limit, Reschedule: reschedule}
+	if err := g.DB.Create(&deck).Error; err != nil {
+		return deck, 0, err
+	}
+	pulled, err := g.RebuildFilteredDeck(deck.ID)
+	return deck, pulled, err
+}
+
+// RebuildFilteredDeck sends the deck's cards home and searches again. Cards
+// already borrowed by another filtered deck are left alone.
+func (g *GormDB) RebuildFilteredDeck(id uint) (int64, error) {
+	deck, err := g.GetDeckByID(id)
+	if err != nil {
+		return 0, err
+	}
+	if deck.Filter == "" {
+		return 0, ErrNotFilteredDeck
+	}
+
+	query, err := search.Parse(deck.Filter)
+	if err != nil {
+		return 0, err
+	}
+	c := searchCompiler{g: g, fts: HasFTS5(g.DB)}
+	where, args, err := c.compile(query)
+	if err != nil {
+		return 0, err
+	}
+
+	var pulled int64
+	err = g.DB.Transaction(func(tx *gorm.DB) error {
+		if err := emptyFilteredDeck(tx, id); err != nil {
+			return err
+		}
+
+		var cards []models.Card
+		err := g.cardsIn(tx).
+			Where("cards.home_deck_id IS NULL AND cards.deck_id != ?", id).
+			Where("("+where+")", args...).
+			Order("cards.review_due_date ASC, cards.id ASC").
+			Limit(deck.FilterLimit).
+			Find(&cards).Error
+		if err != nil {
+			return err
+		}
+
+		for i := range cards {
+			home := cards[i].DeckID
+			cards[i].HomeDeckID = &home
+			cards[i].DeckID = id
+		}
+		pulled = int64(len(cards))
+		return g.saveProgress(tx, cards...)
+	})
+	return pulled, err
+}
+
+// EmptyFilteredDeck sends every borrowed card back to its home deck
+func (g *GormDB) EmptyFilteredDeck(id uint) error {
+	deck, err := g.GetDeckByID(id)
+	if err != nil {
+		return err
+	}
+	if deck.Filter == "" {
+		return ErrNotFilteredDeck
+	}
+	return emptyFilteredDeck(g.DB, id)
+}
+
+func emptyFilteredDeck(tx *gorm.DB, id uint) error {
+	return tx.Model(&models.CardProgress{}).
+		Where("filtered_deck_id = ?", id).
+		Update("filtered_deck_id", nil).Error
+}
+
+// Filtered decks ignore due dates, the point is to study ahead
+func (g *GormDB) getFilteredDeckCards(deckID uint, limit int, cardStage string, tags ...string) ([]models.Card, error) {
+	var cards []models.Card
+	err := g.cards().
+		Scopes(withTags(tags)).
+		Where("deck_id = ? AND stage = ?", deckID, cardStage).
+		Order("review_due_date ASC").
+		Limit(limit).
+		Find(&cards).Error
+	return cards, err
+}
+
+// reschedules reports whether answering the card should change its
+// scheduling, which is only skipped in filtered decks set up that way
+func (g *GormDB) reschedules(card models.Card) (bool, error) {
+	if card.HomeDeckID == nil {
+		return true, nil
+	}
+	deck, err := g.GetDeckByID(card.DeckID)
+	if err != nil {
+		return false, err
+	}
+	return deck.Reschedule, nil
+}
+
+// answerWithoutRescheduling only logs the answer. The card goes home once it
+// is answered correctly.
+func (g *GormDB) answerWithoutRescheduling(card models.Card, answer string, correct bool, duration time.Duration) (models.Card, error) {
+	after := card
+	if correct {
+		returnHome(&after)
+	}
+	return after, g.logCramAnswer(card, after, answer, correct, duration)
+}
+
+func returnHome(card *models.Card) {
+	if card.HomeDeckID != nil {
+		card.DeckID = *card.HomeDeckID
+		card.HomeDeckID = nil
+	}
+}
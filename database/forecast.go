@@ -0,0 +1,93 @@
+package database
+
+import (
+	"time"
+	"webproject/spacedrepetition"
+)
+
+type ForecastDay struct {
+	Date           string `json:"date"`
+	Reviews        int    `json:"reviews"`
+	NewCards       int    `json:"new_cards"`
+	ReviewsFromNew int    `json:"reviews_from_new"`
+}
+
+// GetForecast buckets review due dates into study days, starting today.
+// Overdue cards count towards today. deckID 0 forecasts every deck. When
+// newPerDay is set, that many learning cards per deck are assumed to be
+// learned each day and their reviews are projected assuming they are
+// answered correctly on time.
+func (g *GormDB) GetForecast(deckID uint, days int, boundary DayBoundary, newPerDay int) ([]ForecastDay, error) {
+	today := boundary.StartOfDay(g.Now())
+	horizon := today.AddDate(0, 0, days)
+
+	forecast := make([]ForecastDay, days)
+	for i := range forecast {
+		forecast[i].Date = boundary.Date(today.AddDate(0, 0, i))
+	}
+	first := boundary.dayNumber(today)
+	dayIndex := func(t time.Time) int {
+		return min(max(boundary.dayNumber(t)-first, 0), days-1)
+	}
+
+	var due []time.Time
+	query := g.cards().
+		Where("stage = ? AND review_due_date < ?", "review", horizon.UTC())
+	if deckID != 0 {
+		query = query.Where("deck_id = ?", deckID)
+	}
+	if err := query.Pluck("review_due_date", &due).Error; err != nil {
+		return nil, err
+	}
+	for _, d := range due {
+		forecast[dayIndex(d)].Reviews++
+	}
+
+	if newPerDay <= 0 {
+		return forecast, nil
+	}
+
+	var waiting []struct {
+		DeckID uint
+		Count  int
+	}
+	query = g.cards().
+		Select("deck_id, COUNT(*) AS count").
+		Where("stage = ?", "learning").
+		Group("deck_id")
+	if deckID != 0 {
+		query = query.Where("deck_id = ?", deckID)
+	}
+	if err := query.Scan(&waiting).Error; err != nil {
+		return nil, err
+	}
+
+	for _, deck := range waiting {
+		options, err := g.GetDeckOptions(deck.DeckID)
+		if err != nil {
+			return nil, err
+		}
+		weights := spacedrepetition.ValidFSRSWeights(options.FSRSWeights)
+
+		remaining := deck.Count
+		for day := 0; day < days && remaining > 0; day++ {
+			introduced := min(newPerDay, remaining)
+			remaining -= introduced
+			forecast[day].NewCards += introduced
+
+			learnedAt := today.AddDate(0, 0, day)
+			state := spacedrepetition.InitialMemoryState(weights, spacedrepetition.GradeGood)
+			interval := spacedrepetition.NextReviewInterval(state.Stability, options.DesiredRetention)
+			for next := learnedAt.Add(interval); next.Before(horizon); next = next.Add(interval) {
+				i := dayIndex(next)
+				forecast[i].Reviews += introduced
+				forecast[i].ReviewsFromNew += introduced
+
+				state = spacedrepetition.NextMemoryState(weights, state, interval.Hours()/24, spacedrepetition.GradeGood)
+				interval = spacedrepetition.NextReviewInterval(state.Stability, options.DesiredRetention)
+			}
+		}
+	}
+
+	return forecast, nil
+}
@@ -0,0 +1,51 @@
+package database
+
+import (
+	"webproject/models"
+
+	"gorm.io/gorm"
+)
+
+func Migrate(db *gorm.DB) error {
+	// deck options used to be unique per deck, they are per user and deck now
+	if db.Migrator().HasIndex(&models.DeckOptions{}, "idx_deck_options_deck_id") {
+		if err := db.Migrator().DropIndex(&models.DeckOptions{}, "idx_deck_options_deck_id"); err != nil {
+			return err
+		}
+	}
+	hadProgress := db.Migrator().HasTable(&models.CardProgress{})
+
+	err := db.AutoMigrate(
+		&models.Deck{},
+		&models.Card{},
+		&models.CardProgress{},
+		&models.ReviewLog{},
+		&models.DeckOptions{},
+		&models.Settings{},
+		&models.Tag{},
+		&models.User{},
+		&models.Session{},
+		&models.APIToken{},
+		&models.DeckMember{},
+		&models.DeckInvite{},
+		&models.Class{},
+		&models.ClassMember{},
+		&models.ClassDeck{},
+		&models.Assignment{},
+		&models.AssignmentCompletion{},
+		&models.CardDeletion{},
+	)
+	if err != nil {
+		return err
+	}
+	// cards from before sync count as last edited when they were added
+	if err := db.Exec("UPDATE cards SET updated_at = card_created WHERE updated_at IS NULL").Error; err != nil {
+		return err
+	}
+	if !hadProgress {
+		if err := migrateLegacyProgress(db); err != nil {
+			return err
+		}
+	}
+	return setupCardSearch(db)
+}
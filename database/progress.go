@@ -0,0 +1,122 @@
+package database
+
+import (
+	"webproject/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// cardsView stands in for the cards table whenever cards are read. It only
+// has cards of decks the user can open, leaving out the trash, and puts
+// their own progress next to the shared content. Cards they have never
+// answered come out as new learning cards due since they were added.
+//
+// It is a UNION rather than one LEFT JOIN with COALESCEs so the timestamps
+// stay plain columns. sqlite only reports the declared type of those, and
+// the driver needs it to parse them back into times.
+const cardsView = `SELECT cards.id, COALESCE(p.filtered_deck_id, cards.deck_id) AS deck_id,
+		CASE WHEN p.filtered_deck_id IS NOT NULL THEN cards.deck_id END AS home_deck_id,
+		p.correct, p.incorrect, cards.card_created, p.last_review_date, p.stage, p.lapses,
+		p.ease, p.review_due_date, p.stability, p.difficulty,
+		cards.question, cards.answer, cards.extra, cards.audio, cards.image, cards.updated_at,
+		cards.deleted_at
+	FROM cards JOIN card_progresses p ON p.card_id = cards.id AND p.user_id = @user
+	WHERE cards.deleted_at IS NULL AND cards.deck_id IN (SELECT id FROM (` + accessibleDecks + `))
+	UNION ALL
+	SELECT cards.id, cards.deck_id, NULL, 0, 0, cards.card_created, p.last_review_date, 'learning', 0,
+		1, cards.card_created, 0, 0,
+		cards.question, cards.answer, cards.extra, cards.audio, cards.image, cards.updated_at,
+		cards.deleted_at
+	FROM cards LEFT JOIN card_progresses p ON p.card_id = cards.id AND p.user_id = @user
+	WHERE p.id IS NULL AND cards.deleted_at IS NULL AND cards.deck_id IN (SELECT id FROM (` + accessibleDecks + `))`
+
+// cards starts a query over the user's view of the cards table
+func (g *GormDB) cards() *gorm.DB {
+	return g.cardsIn(g.DB)
+}
+
+func (g *GormDB) cardsIn(db *gorm.DB) *gorm.DB {
+	return db.Table("(?) AS cards", db.Raw(cardsView, map[string]any{"user": g.UserID}))
+}
+
+func progressOf(userID uint, card models.Card) models.CardProgress {
+	progress := models.CardProgress{
+		UserID:         userID,
+		CardID:         card.ID,
+		Correct:        card.Correct,
+		Incorrect:      card.Incorrect,
+		LastReviewDate: card.LastReviewDate,
+		Stage:          card.Stage,
+		Lapses:         card.Lapses,
+		Ease:           card.Ease,
+		ReviewDueDate:  card.ReviewDueDate,
+		Stability:      card.Stability,
+		Difficulty:     card.Difficulty,
+	}
+	if card.HomeDeckID != nil {
+		filtered := card.DeckID
+		progress.FilteredDeckID = &filtered
+	}
+	return progress
+}
+
+// saveProgress writes the scheduling fields of cards read through the view
+// back as the user's progress
+func (g *GormDB) saveProgress(tx *gorm.DB, cards ...models.Card) error {
+	if len(cards) == 0 {
+		return nil
+	}
+	progress := make([]models.CardProgress, len(cards))
+	for i, card := range cards {
+		progress[i] = progressOf(g.UserID, card)
+	}
+	return tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "card_id"}},
+		UpdateAll: true,
+	}).Create(&progress).Error
+}
+
+// migrateLegacyProgress moves scheduling state that used to live on the cards
+// table into progress rows of user 0, who stands for the single learner from
+// before accounts. Databases made before filtered decks or FSRS lack some
+// columns, those start from their defaults.
+func migrateLegacyProgress(db *gorm.DB) error {
+	var columns []string
+	if err := db.Raw("SELECT name FROM pragma_table_info('cards')").Scan(&columns).Error; err != nil {
+		return err
+	}
+	has := map[string]bool{}
+	for _, c := range columns {
+		has[c] = true
+	}
+	if !has["stage"] {
+		return nil
+	}
+
+	column := func(name string, fallback string) string {
+		if has[name] {
+			return "COALESCE(" + name + ", " + fallback + ")"
+		}
+		return fallback
+	}
+	filtered := "NULL"
+	if has["home_deck_id"] {
+		filtered = "CASE WHEN home_deck_id IS NOT NULL THEN deck_id END"
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Exec(`INSERT INTO card_progresses (user_id, card_id, filtered_deck_id, correct, incorrect,
+				last_review_date, stage, lapses, ease, review_due_date, stability, difficulty)
+			SELECT 0, id, ` + filtered + `, ` + column("correct", "0") + `, ` + column("incorrect", "0") + `,
+				last_review_date, ` + column("stage", "'learning'") + `, ` + column("lapses", "0") + `,
+				` + column("ease", "1") + `, review_due_date, ` + column("stability", "0") + `,
+				` + column("difficulty", "0") + `
+			FROM cards`).Error
+		if err != nil || !has["home_deck_id"] {
+			return err
+		}
+		// the cards table only knows home decks now
+		return tx.Exec("UPDATE cards SET deck_id = home_deck_id, home_deck_id = NULL WHERE home_deck_id IS NOT NULL").Error
+	})
+}
@@ -0,0 +1,142 @@
+package database
+
+import (
+	"time"
+	"webproject/models"
+	"webproject/spacedrepetition"
+
+	"gorm.io/gorm"
+)
+
+// Answers slower than this are capped, the learner most likely walked away
+const maxAnswerDuration = 5 * time.Minute
+
+type answerEvent struct {
+	given    string
+	correct  bool
+	grade    int
+	duration time.Duration
+	at       time.Time
+	cram     bool
+}
+
+func newAnswerEvent(given string, correct bool, duration time.Duration, options models.DeckOptions, now time.Time) answerEvent {
+	duration = min(max(duration, 0), maxAnswerDuration)
+	slowAfter := time.Duration(options.SlowAnswerSeconds * float64(time.Second))
+	return answerEvent{
+		given:    given,
+		correct:  correct,
+		grade:    spacedrepetition.GradeAnswer(correct, duration, slowAfter),
+		duration: duration,
+		at:       now,
+	}
+}
+
+func newReviewLog(before models.Card, after models.Card, event answerEvent) *models.ReviewLog {
+	var filteredDeckID *uint
+	if before.HomeDeckID != nil {
+		filtered := before.DeckID
+		filteredDeckID = &filtered
+	}
+	return &models.ReviewLog{
+		CardID:           after.ID,
+		DeckID:           homeDeckID(after),
+		FilteredDeckID:   filteredDeckID,
+		ReviewedAt:       event.at,
+		Stage:            before.Stage,
+		StageAfter:       after.Stage,
+		Grade:            event.grade,
+		Correct:          event.correct,
+		Answer:           event.given,
+		DurationMs:       event.duration.Milliseconds(),
+		EaseBefore:       before.Ease,
+		EaseAfter:        after.Ease,
+		LastReviewBefore: before.LastReviewDate,
+		DueBefore:        before.ReviewDueDate,
+		DueAfter:         after.ReviewDueDate,
+		StabilityBefore:  before.Stability,
+		StabilityAfter:   after.Stability,
+		DifficultyAfter:  after.Difficulty,
+		Cram:             event.cram,
+	}
+}
+
+func (g *GormDB) reviewLogs() *gorm.DB {
+	return g.DB.Model(&models.ReviewLog{}).Where("review_logs.user_id = ?", g.UserID)
+}
+
+// deckID 0 returns the history of every deck
+func (g *GormDB) GetReviewLogs(deckID uint) ([]models.ReviewLog, error) {
+	var logs []models.ReviewLog
+	query := g.reviewLogs().Order("card_id ASC, reviewed_at ASC, id ASC")
+	if deckID != 0 {
+		query = query.Where("deck_id = ?", deckID)
+	}
+	err := query.Find(&logs).Error
+	return logs, err
+}
+
+type CardHistoryEntry struct {
+	ReviewedAt         time.Time `json:"reviewed_at"`
+	Grade              int       `json:"grade"`
+	Correct            bool      `json:"correct"`
+	Answer             string    `json:"answer"`
+	StageBefore        string    `json:"stage_before"`
+	StageAfter         string    `json:"stage_after"`
+	EaseBefore         uint      `json:"ease_before"`
+	EaseAfter          uint      `json:"ease_after"`
+	DurationSeconds    *float64  `json:"duration_seconds"`
+	ElapsedDays        *float64  `json:"elapsed_days"`
+	IntervalBeforeDays *float64  `json:"interval_before_days"`
+	IntervalAfterDays  *float64  `json:"interval_after_days"`
+	Stability          float64   `json:"stability"`
+	Difficulty         float64   `json:"difficulty"`
+	Cram               bool      `json:"cram"`
+}
+
+// Entries logged before intervals were recorded have nil intervals
+func (g *GormDB) GetCardHistory(cardID uint) ([]CardHistoryEntry, error) {
+	var logs []models.ReviewLog
+	err := g.reviewLogs().Where("card_id = ?", cardID).Order("reviewed_at ASC, id ASC").Find(&logs).Error
+	if err != nil {
+		return nil, err
+	}
+
+	days := func(from time.Time, to time.Time) *float64 {
+		if from.IsZero() || to.IsZero() {
+			return nil
+		}
+		d := to.Sub(from).Hours() / 24
+		return &d
+	}
+
+	history := make([]CardHistoryEntry, len(logs))
+	for i, l := range logs {
+		history[i] = CardHistoryEntry{
+			ReviewedAt:         l.ReviewedAt,
+			Grade:              spacedrepetition.LoggedGrade(l),
+			Correct:            l.Correct,
+			Answer:             l.Answer,
+			StageBefore:        l.Stage,
+			StageAfter:         l.StageAfter,
+			EaseBefore:         l.EaseBefore,
+			EaseAfter:          l.EaseAfter,
+			DurationSeconds:    answerSeconds(l.DurationMs),
+			ElapsedDays:        days(l.LastReviewBefore, l.ReviewedAt),
+			IntervalBeforeDays: days(l.LastReviewBefore, l.DueBefore),
+			IntervalAfterDays:  days(l.ReviewedAt, l.DueAfter),
+			Stability:          l.StabilityAfter,
+			Difficulty:         l.DifficultyAfter,
+			Cram:               l.Cram,
+		}
+	}
+	return history, nil
+}
+
+func answerSeconds(durationMs int64) *float64 {
+	if durationMs <= 0 {
+		return nil
+	}
+	s := float64(durationMs) / 1000
+	return &s
+}
@@ -0,0 +1,220 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+	"webproject/models"
+	"webproject/search"
+
+	"gorm.io/gorm"
+)
+
+// The full text index needs sqlite built with FTS5, which go-sqlite3 only
+// does with the sqlite_fts5 build tag:
+//
+//	go build -tags sqlite_fts5
+//
+// Without it text terms fall back to LIKE, which the server warns about.
+var cardSearchSetup = []string{
+	`CREATE VIRTUAL TABLE IF NOT EXISTS cards_fts USING fts5(question, answer, content='cards', content_rowid='id')`,
+	`CREATE TRIGGER IF NOT EXISTS cards_fts_insert AFTER INSERT ON cards BEGIN
+		INSERT INTO cards_fts(rowid, question, answer) VALUES (new.id, new.question, new.answer);
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS cards_fts_delete AFTER DELETE ON cards BEGIN
+		INSERT INTO cards_fts(cards_fts, rowid, question, answer) VALUES ('delete', old.id, old.question, old.answer);
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS cards_fts_update AFTER UPDATE OF question, answer ON cards BEGIN
+		INSERT INTO cards_fts(cards_fts, rowid, question, answer) VALUES ('delete', old.id, old.question, old.answer);
+		INSERT INTO cards_fts(rowid, question, answer) VALUES (new.id, new.question, new.answer);
+	END`,
+	// cards written by a build without FTS5 never reached the index
+	`INSERT INTO cards_fts(cards_fts) VALUES ('rebuild')`,
+}
+
+// HasFTS5 reports whether the sqlite linked in has full text search
+func HasFTS5(db *gorm.DB) bool {
+	var count int64
+	db.Raw("SELECT count(*) FROM pragma_module_list WHERE name = 'fts5'").Scan(&count)
+	return count > 0
+}
+
+func setupCardSearch(db *gorm.DB) error {
+	if !HasFTS5(db) {
+		// triggers left behind by an FTS5 build would make every card
+		// insert fail here
+		for _, trigger := range []string{"cards_fts_insert", "cards_fts_delete", "cards_fts_update"} {
+			if err := db.Exec("DROP TRIGGER IF EXISTS " + trigger).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for _, statement := range cardSearchSetup {
+		if err := db.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SearchCards returns one page of the cards matching query, ordered by id,
+// together with the number of matches across all pages. A nil query matches
+// every card.
+func (g *GormDB) SearchCards(query search.Node, offset int, limit int) ([]models.Card, int64, error) {
+	c := searchCompiler{g: g, fts: HasFTS5(g.DB)}
+
+	db := g.cards()
+	if query != nil {
+		where, args, err := c.compile(query)
+		if err != nil {
+			return nil, 0, err
+		}
+		db = db.Where(where, args...)
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var cards []models.Card
+	err := db.Preload("Tags").Order("cards.id ASC").Offset(offset).Limit(limit).Find(&cards).Error
+	return cards, total, err
+}
+
+type searchCompiler struct {
+	g     *GormDB
+	fts   bool
+	decks []DeckNode
+}
+
+func (c *searchCompiler) compile(node search.Node) (string, []any, error) {
+	switch n := node.(type) {
+	case search.And:
+		return c.join(n.Children, " AND ")
+	case search.Or:
+		return c.join(n.Children, " OR ")
+	case search.Not:
+		where, args, err := c.compile(n.Child)
+		return "NOT (" + where + ")", args, err
+	case search.Term:
+		return c.term(n)
+	}
+	return "", nil, fmt.Errorf("unexpected search node %T", node)
+}
+
+func (c *searchCompiler) join(children []search.Node, operator string) (string, []any, error) {
+	parts := make([]string, 0, len(children))
+	var args []any
+	for _, child := range children {
+		where, childArgs, err := c.compile(child)
+		if err != nil {
+			return "", nil, err
+		}
+		parts = append(parts, "("+where+")")
+		args = append(args, childArgs...)
+	}
+	return strings.Join(parts, operator), args, nil
+}
+
+func (c *searchCompiler) term(t search.Term) (string, []any, error) {
+	op := t.Op
+	if op == ":" {
+		op = "="
+	}
+
+	switch t.Field {
+	case "text", "question", "answer":
+		return c.text(t.Field, t.Value)
+	case "tag":
+		tag := strings.ToLower(t.Value)
+		if strings.Contains(tag, "*") {
+			return `cards.id IN (SELECT card_tags.card_id FROM card_tags
+				JOIN tags ON tags.id = card_tags.tag_id WHERE tags.name LIKE ? ESCAPE '\')`,
+				[]any{strings.ReplaceAll(escapeLike(tag), "*", "%")}, nil
+		}
+		return `cards.id IN (SELECT card_tags.card_id FROM card_tags
+			JOIN tags ON tags.id = card_tags.tag_id WHERE tags.name = ?)`, []any{tag}, nil
+	case "deck":
+		ids, err := c.deckIDs(t.Value)
+		if err != nil {
+			return "", nil, err
+		}
+		if len(ids) == 0 {
+			return "1 = 0", nil, nil
+		}
+		return "(cards.deck_id IN ? OR cards.home_deck_id IN ?)", []any{ids, ids}, nil
+	case "stage":
+		return "cards.stage = ?", []any{strings.ToLower(t.Value)}, nil
+	case "ease", "lapses", "correct", "incorrect":
+		return fmt.Sprintf("cards.%s %s ?", t.Field, op), []any{t.Number}, nil
+	case "due":
+		// only review cards have a meaningful due date, learning cards are
+		// always shown in order
+		return fmt.Sprintf("cards.stage = 'review' AND cards.review_due_date %s ?", op),
+			[]any{c.g.Now().AddDate(0, 0, t.Number).UTC()}, nil
+	case "lapsed":
+		return `cards.id IN (SELECT card_id FROM review_logs
+			WHERE user_id = ? AND stage = 'review' AND correct = 0 AND cram = 0 AND reviewed_at >= ?)`,
+			[]any{c.g.UserID, c.g.Now().AddDate(0, 0, -t.Number).UTC()}, nil
+	case "added":
+		return "cards.card_created >= ?", []any{c.g.Now().AddDate(0, 0, -t.Number).UTC()}, nil
+	}
+	return "", nil, fmt.Errorf("unknown field %q", t.Field)
+}
+
+func (c *searchCompiler) text(field string, value string) (string, []any, error) {
+	value = strings.TrimSuffix(value, "*")
+	if value == "" {
+		return "1 = 1", nil, nil
+	}
+
+	if c.fts {
+		// every word of value is matched as a prefix phrase, so "hab" finds
+		// "hablar"
+		phrase := `"` + strings.ReplaceAll(value, `"`, `""`) + `"*`
+		if field != "text" {
+			phrase = field + " : " + phrase
+		}
+		return "cards.id IN (SELECT rowid FROM cards_fts WHERE cards_fts MATCH ?)", []any{phrase}, nil
+	}
+
+	pattern := "%" + escapeLike(value) + "%"
+	if field == "text" {
+		return `(cards.question LIKE ? ESCAPE '\' OR cards.answer LIKE ? ESCAPE '\')`, []any{pattern, pattern}, nil
+	}
+	return fmt.Sprintf(`cards.%s LIKE ? ESCAPE '\'`, field), []any{pattern}, nil
+}
+
+// deckIDs finds decks by name or full path, case-insensitively, and includes
+// their subdecks
+func (c *searchCompiler) deckIDs(name string) ([]uint, error) {
+	if c.decks == nil {
+		tree, err := c.g.GetDeckTree()
+		if err != nil {
+			return nil, err
+		}
+		c.decks = tree
+	}
+
+	var ids []uint
+	var collect func(node DeckNode, matched bool)
+	collect = func(node DeckNode, matched bool) {
+		matched = matched || strings.EqualFold(node.Name, name) || strings.EqualFold(node.FullName, name)
+		if matched {
+			ids = append(ids, node.ID)
+		}
+		for _, child := range node.Children {
+			collect(child, matched)
+		}
+	}
+	for _, root := range c.decks {
+		collect(root, false)
+	}
+	return ids, nil
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
@@ -0,0 +1,69 @@
+package database
+
+import (
+	"time"
+	"webproject/models"
+)
+
+const defaultDayRolloverHour = 4
+
+func (g *GormDB) GetSettings() (models.Settings, error) {
+	settings := models.Settings{UserID: g.UserID, Timezone: "UTC", DayRolloverHour: defaultDayRolloverHour}
+	err := g.DB.Where("user_id = ?", g.UserID).FirstOrInit(&settings).Error
+	return settings, err
+}
+
+// SaveSettings replaces the user's settings, creating them the first time
+func (g *GormDB) SaveSettings(settings models.Settings) error {
+	existing, err := g.GetSettings()
+	if err != nil {
+		return err
+	}
+	settings.ID = existing.ID
+	settings.UserID = g.UserID
+	return g.DB.Save(&settings).Error
+}
+
+// DayBoundary decides which study day a moment belongs to. Reviews done
+// before the rollover hour count towards the previous day.
+type DayBoundary struct {
+	Location     *time.Location
+	RolloverHour int
+}
+
+func (g *GormDB) GetDayBoundary() (DayBoundary, error) {
+	settings, err := g.GetSettings()
+	if err != nil {
+		return DayBoundary{Location: time.UTC}, err
+	}
+	loc, err := time.LoadLocation(settings.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	return DayBoundary{Location: loc, RolloverHour: settings.DayRolloverHour}, nil
+}
+
+func (b DayBoundary) StartOfDay(t time.Time) time.Time {
+	y, m, d := t.In(b.Location).Add(-time.Duration(b.RolloverHour) * time.Hour).Date()
+	return time.Date(y, m, d, b.RolloverHour, 0, 0, 0, b.Location)
+}
+
+// Date is the calendar date a study day is named after
+func (b DayBoundary) Date(t time.Time) string {
+	return b.StartOfDay(t).Format("2006-01-02")
+}
+
+// ParseDate returns the start of the study day named by a YYYY-MM-DD date
+func (b DayBoundary) ParseDate(date string) (time.Time, error) {
+	d, err := time.ParseInLocation("2006-01-02", date, b.Location)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Date(d.Year(), d.Month(), d.Day(), b.RolloverHour, 0, 0, 0, b.Location), nil
+}
+
+// dayNumber counts calendar days so DST changes don't shift buckets
+func (b DayBoundary) dayNumber(t time.Time) int {
+	y, m, d := b.StartOfDay(t).Date()
+	return int(time.Date(y, m, d, 0, 0, 0, 0, time.UTC).Unix() / 86400)
+}
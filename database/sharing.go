@@ -0,0 +1,344 @@
+package database
+
+import (
+	"errors"
+	"webproject/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DeckRoles from least to most access, each allowing what the ones before
+// it do
+var DeckRoles = []string{"viewer", "editor", "owner"}
+
+var (
+	ErrUnknownRole    = errors.New("role must be editor or viewer")
+	ErrUserNotFound   = errors.New("user not found")
+	ErrAlreadyMember  = errors.New("user already has access to this deck")
+	ErrInviteNotFound = errors.New("invite not found")
+	ErrNotMember      = errors.New("user is not a member of this deck")
+)
+
+func roleLevel(role string) int {
+	for i, r := range DeckRoles {
+		if r == role {
+			return i
+		}
+	}
+	return -1
+}
+
+// RoleAllows reports whether role have may do what needs role need
+func RoleAllows(have string, need string) bool {
+	level := roleLevel(have)
+	return level >= 0 && level >= roleLevel(need)
+}
+
+// accessibleDecks lists the decks the user can open together with their role
+// on each: decks they own, decks shared with them or their classes and the
+// subdecks of all those. A deck reached more than one way is listed once per
+// role. Decks in the trash keep their members but can't be opened.
+const accessibleDecks = `WITH RECURSIVE accessible(id, role) AS (
+		SELECT id, 'owner' FROM decks WHERE owner_id = @user
+		UNION SELECT deck_id, role FROM deck_members WHERE user_id = @user
+		UNION SELECT class_decks.deck_id, 'viewer' FROM class_decks
+			JOIN class_members ON class_members.class_id = class_decks.class_id
+			WHERE class_members.user_id = @user
+		UNION SELECT decks.id, accessible.role FROM decks JOIN accessible ON decks.parent_id = accessible.id
+	)
+	SELECT id, role FROM accessible WHERE id IN (SELECT id FROM decks WHERE deleted_at IS NULL)`
+
+// decksWithRole selects the ids of decks the user has at least role on
+func (g *GormDB) decksWithRole(role string) *gorm.DB {
+	roles := DeckRoles[max(roleLevel(role), 0):]
+	return g.DB.Raw("SELECT id FROM ("+accessibleDecks+") WHERE role IN @roles",
+		map[string]any{"user": g.UserID, "roles": roles})
+}
+
+// visibleDecks keeps the decks the user can open
+func (g *GormDB) visibleDecks(db *gorm.DB) *gorm.DB {
+	return db.Where("decks.id IN (?)", g.decksWithRole("viewer"))
+}
+
+func (g *GormDB) editableDecks(db *gorm.DB) *gorm.DB {
+	return db.Where("decks.id IN (?)", g.decksWithRole("editor"))
+}
+
+// DeckRole is the user's role on a deck, the best one when it is shared with
+// them more than one way. It is empty when they can't open the deck.
+func (g *GormDB) DeckRole(deckID uint) (string, error) {
+	var roles []string
+	err := g.DB.Raw("SELECT role FROM ("+accessibleDecks+") WHERE id = @deck",
+		map[string]any{"user": g.UserID, "deck": deckID}).
+		Scan(&roles).Error
+	if err != nil {
+		return "", err
+	}
+
+	best := ""
+	for _, role := range roles {
+		if roleLevel(role) > roleLevel(best) {
+			best = role
+		}
+	}
+	return best, nil
+}
+
+// CardRole is the user's role on the card's home deck
+func (g *GormDB) CardRole(cardID uint) (string, error) {
+	var card models.Card
+	if err := g.DB.Select("id", "deck_id").First(&card, cardID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", nil
+		}
+		return "", err
+	}
+	return g.DeckRole(card.DeckID)
+}
+
+// CanEditCards reports whether the user may change every one of the cards
+func (g *GormDB) CanEditCards(cardIDs []uint) (bool, error) {
+	if len(cardIDs) == 0 {
+		return true, nil
+	}
+	unique := map[uint]bool{}
+	for _, id := range cardIDs {
+		unique[id] = true
+	}
+
+	var editable int64
+	err := g.DB.Model(&models.Card{}).
+		Where("id IN ? AND deck_id IN (?)", cardIDs, g.decksWithRole("editor")).
+		Count(&editable).Error
+	return editable == int64(len(unique)), err
+}
+
+type DeckMemberInfo struct {
+	UserID   uint   `json:"user_id"`
+	Username string `json:"username"`
+	Role     string `json:"role"`
+}
+
+// GetDeckMembers lists the deck's owner followed by the users it is shared
+// with directly
+func (g *GormDB) GetDeckMembers(deckID uint) ([]DeckMemberInfo, error) {
+	deck, err := g.GetDeckByID(deckID)
+	if err != nil {
+		return nil, err
+	}
+
+	members := []DeckMemberInfo{}
+	var owner models.User
+	found := g.DB.Limit(1).Find(&owner, deck.OwnerID)
+	if found.Error != nil {
+		return nil, found.Error
+	}
+	if found.RowsAffected > 0 {
+		members = append(members, DeckMemberInfo{UserID: owner.ID, Username: owner.Username, Role: "owner"})
+	}
+
+	var shared []DeckMemberInfo
+	err = g.DB.Model(&models.DeckMember{}).
+		Select("deck_members.user_id, users.username, deck_members.role").
+		Joins("JOIN users ON users.id = deck_members.user_id").
+		Where("deck_members.deck_id = ?", deckID).
+		Order("users.username ASC").
+		Scan(&shared).Error
+	return append(members, shared...), err
+}
+
+func validMemberRole(role string) bool {
+	return role == "editor" || role == "viewer"
+}
+
+// InviteToDeck invites a user by name to the deck. Inviting them again
+// replaces the pending invite's role.
+func (g *GormDB) InviteToDeck(deckID uint, username string, role string) (models.DeckInvite, error) {
+	invite := models.DeckInvite{DeckID: deckID, InvitedBy: g.UserID, Role: role, CreatedAt: g.Now()}
+	if !validMemberRole(role) {
+		return invite, ErrUnknownRole
+	}
+
+	var user models.User
+	if err := g.DB.Where("username = ?", username).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return invite, ErrUserNotFound
+		}
+		return invite, err
+	}
+	invite.UserID = user.ID
+
+	existing, err := g.ForUser(user.ID).DeckRole(deckID)
+	if err != nil {
+		return invite, err
+	}
+	if existing != "" {
+		return invite, ErrAlreadyMember
+	}
+
+	err = g.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("deck_id = ? AND user_id = ?", deckID, user.ID).Delete(&models.DeckInvite{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&invite).Error
+	})
+	return invite, err
+}
+
+type PendingInvite struct {
+	ID        uint   `json:"id"`
+	DeckID    uint   `json:"deck_id"`
+	DeckName  string `json:"deck_name"`
+	UserID    uint   `json:"user_id"`
+	Username  string `json:"username"`
+	InvitedBy string `json:"invited_by"`
+	Role      string `json:"role"`
+}
+
+func (g *GormDB) pendingInvites() *gorm.DB {
+	return g.DB.Model(&models.DeckInvite{}).
+		Select(`deck_invites.id, deck_invites.deck_id, decks.name AS deck_name, deck_invites.user_id,
+			invited.username AS username, inviter.username AS invited_by, deck_invites.role`).
+		Joins("JOIN decks ON decks.id = deck_invites.deck_id AND decks.deleted_at IS NULL").
+		Joins("JOIN users AS invited ON invited.id = deck_invites.user_id").
+		Joins("LEFT JOIN users AS inviter ON inviter.id = deck_invites.invited_by").
+		Order("deck_invites.created_at DESC, deck_invites.id DESC")
+}
+
+// GetInvites lists the invites waiting for the user to accept
+func (g *GormDB) GetInvites() ([]PendingInvite, error) {
+	invites := []PendingInvite{}
+	err := g.pendingInvites().Where("deck_invites.user_id = ?", g.UserID).Scan(&invites).Error
+	return invites, err
+}
+
+// GetDeckInvites lists the deck's invites nobody has accepted yet
+func (g *GormDB) GetDeckInvites(deckID uint) ([]PendingInvite, error) {
+	invites := []PendingInvite{}
+	err := g.pendingInvites().Where("deck_invites.deck_id = ?", deckID).Scan(&invites).Error
+	return invites, err
+}
+
+// AcceptInvite turns one of the user's invites into a membership
+func (g *GormDB) AcceptInvite(inviteID uint) (models.DeckMember, error) {
+	var member models.DeckMember
+	err := g.DB.Transaction(func(tx *gorm.DB) error {
+		var invite models.DeckInvite
+		if err := tx.Where("id = ? AND user_id = ?", inviteID, g.UserID).First(&invite).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInviteNotFound
+			}
+			return err
+		}
+
+		member = models.DeckMember{DeckID: invite.DeckID, UserID: g.UserID, Role: invite.Role, CreatedAt: g.Now()}
+		err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "deck_id"}, {Name: "user_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"role"}),
+		}).Create(&member).Error
+		if err != nil {
+			return err
+		}
+		return tx.Delete(&invite).Error
+	})
+	return member, err
+}
+
+// DeclineInvite drops one of the user's invites
+func (g *GormDB) DeclineInvite(inviteID uint) error {
+	return g.deleteInvite(g.DB.Where("id = ? AND user_id = ?", inviteID, g.UserID))
+}
+
+// CancelDeckInvite withdraws an invite to the deck
+func (g *GormDB) CancelDeckInvite(deckID uint, inviteID uint) error {
+	return g.deleteInvite(g.DB.Where("id = ? AND deck_id = ?", inviteID, deckID))
+}
+
+func (g *GormDB) deleteInvite(query *gorm.DB) error {
+	result := query.Delete(&models.DeckInvite{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrInviteNotFound
+	}
+	return nil
+}
+
+func (g *GormDB) SetDeckMemberRole(deckID uint, userID uint, role string) error {
+	if !validMemberRole(role) {
+		return ErrUnknownRole
+	}
+	result := g.DB.Model(&models.DeckMember{}).
+		Where("deck_id = ? AND user_id = ?", deckID, userID).
+		Update("role", role)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotMember
+	}
+	return nil
+}
+
+// RemoveDeckMember stops sharing the deck with a user. Their progress is
+// kept in case the deck is shared with them again.
+func (g *GormDB) RemoveDeckMember(deckID uint, userID uint) error {
+	result := g.DB.Where("deck_id = ? AND user_id = ?", deckID, userID).Delete(&models.DeckMember{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotMember
+	}
+	return nil
+}
+
+// deckPath is the deck followed by its ancestors, which it inherits sharing
+// from
+func (g *GormDB) deckPath(deckID uint) ([]uint, error) {
+	parents, err := g.deckParents()
+	if err != nil {
+		return nil, err
+	}
+	path := []uint{deckID}
+	for parent := parents[deckID]; parent != nil && len(path) <= len(parents); parent = parents[*parent] {
+		path = append(path, *parent)
+	}
+	return path, nil
+}
+
+// DeckAudience lists the users who can open the deck, along with the deck's
+// path
+func (g *GormDB) DeckAudience(deckID uint) ([]uint, []uint, error) {
+	path, err := g.deckPath(deckID)
+	if err != nil {
+		return nil, nil, err
+	}
+	var users []uint
+	err = g.DB.Raw(`SELECT owner_id FROM decks WHERE id IN @path
+		UNION SELECT user_id FROM deck_members WHERE deck_id IN @path
+		UNION SELECT class_members.user_id FROM class_members
+			JOIN class_decks ON class_decks.class_id = class_members.class_id
+			WHERE class_decks.deck_id IN @path`,
+		map[string]any{"path": path}).Scan(&users).Error
+	return users, path, err
+}
+
+// ReviewAudience lists who sees the user's answers on the deck: the user and
+// the teachers of their classes the deck is shared with
+func (g *GormDB) ReviewAudience(deckID uint) ([]uint, []uint, error) {
+	path, err := g.deckPath(deckID)
+	if err != nil {
+		return nil, nil, err
+	}
+	var teachers []uint
+	err = g.DB.Model(&models.Class{}).
+		Joins("JOIN class_decks ON class_decks.class_id = classes.id").
+		Joins("JOIN class_members ON class_members.class_id = classes.id").
+		Where("class_decks.deck_id IN ? AND class_members.user_id = ?", path, g.UserID).
+		Distinct().
+		Pluck("classes.teacher_id", &teachers).Error
+	return append(teachers, g.UserID), path, err
+}
@@ -0,0 +1,171 @@
+package database
+
+import (
+	"time"
+	"webproject/models"
+)
+
+const (
+	matureIntervalDays = 21
+	// gaps between answers longer than this are treated as a break, not study
+	maxStudyGap = 5 * time.Minute
+)
+
+type LapseCount struct {
+	Lapses uint  `json:"lapses"`
+	Cards  int64 `json:"cards"`
+}
+
+type RetentionStats struct {
+	Days    int     `json:"days"`
+	Reviews int     `json:"reviews"`
+	Correct int     `json:"correct"`
+	Rate    float64 `json:"rate"`
+}
+
+type DeckStats struct {
+	Stages       map[string]int64 `json:"stages"`
+	DueToday     int64            `json:"due_today"`
+	DueTomorrow  int64            `json:"due_tomorrow"`
+	DueThisWeek  int64            `json:"due_this_week"`
+	AverageEase  float64          `json:"average_ease"`
+	Lapses       []LapseCount     `json:"lapses"`
+	MatureCards  int64            `json:"mature_cards"`
+	YoungCards   int64            `json:"young_cards"`
+	Retention    RetentionStats   `json:"retention"`
+	StudySeconds float64          `json:"study_seconds"`
+	// average over timed answers only, 0 when none are timed
+	AverageAnswerSeconds float64 `json:"average_answer_seconds"`
+}
+
+// GetDeckStats counts "today" using the given day boundary and measures
+// retention over the last windowDays days.
+func (g *GormDB) GetDeckStats(deckID uint, boundary DayBoundary, windowDays int) (DeckStats, error) {
+	stats := DeckStats{Stages: map[string]int64{"learning": 0, "review": 0}}
+	now := g.Now()
+
+	var stageRows []struct {
+		Stage string
+		Count int64
+	}
+	err := g.cards().
+		Select("stage, COUNT(*) AS count").
+		Where("deck_id = ?", deckID).
+		Group("stage").
+		Scan(&stageRows).Error
+	if err != nil {
+		return stats, err
+	}
+	for _, row := range stageRows {
+		stats.Stages[row.Stage] = row.Count
+	}
+
+	// timestamps are stored as UTC strings, so bounds must be UTC to compare
+	tomorrow := boundary.StartOfDay(now).AddDate(0, 0, 1).UTC()
+	dueBefore := func(t time.Time, count *int64) error {
+		return g.cards().
+			Where("deck_id = ? AND stage = ? AND review_due_date < ?", deckID, "review", t).
+			Count(count).Error
+	}
+	if err := dueBefore(tomorrow, &stats.DueToday); err != nil {
+		return stats, err
+	}
+	var dueByDayAfter int64
+	if err := dueBefore(tomorrow.AddDate(0, 0, 1), &dueByDayAfter); err != nil {
+		return stats, err
+	}
+	stats.DueTomorrow = dueByDayAfter - stats.DueToday
+	if err := dueBefore(tomorrow.AddDate(0, 0, 6), &stats.DueThisWeek); err != nil {
+		return stats, err
+	}
+
+	err = g.cards().
+		Select("COALESCE(AVG(ease), 0)").
+		Where("deck_id = ? AND stage = ?", deckID, "review").
+		Scan(&stats.AverageEase).Error
+	if err != nil {
+		return stats, err
+	}
+
+	err = g.cards().
+		Select("lapses, COUNT(*) AS cards").
+		Where("deck_id = ?", deckID).
+		Group("lapses").
+		Order("lapses ASC").
+		Scan(&stats.Lapses).Error
+	if err != nil {
+		return stats, err
+	}
+
+	err = g.cards().
+		Where("deck_id = ? AND stage = ? AND julianday(review_due_date) - julianday(last_review_date) >= ?",
+			deckID, "review", matureIntervalDays).
+		Count(&stats.MatureCards).Error
+	if err != nil {
+		return stats, err
+	}
+	stats.YoungCards = stats.Stages["review"] - stats.MatureCards
+
+	var logs []models.ReviewLog
+	err = g.reviewLogs().Where("deck_id = ? AND reviewed_at >= ? AND cram = ?", deckID, now.AddDate(0, 0, -windowDays), false).
+		Order("reviewed_at ASC, id ASC").
+		Find(&logs).Error
+	if err != nil {
+		return stats, err
+	}
+	stats.Retention = trueRetention(logs, boundary)
+	stats.Retention.Days = windowDays
+
+	// untimed answers fall back to the gap since the previous answer,
+	// ignoring breaks
+	err = g.DB.Raw(`SELECT COALESCE(SUM(CASE
+				WHEN duration_ms > 0 THEN duration_ms / 1000.0
+				WHEN gap > 0 AND gap <= ? THEN gap
+				ELSE 0 END), 0)
+			FROM (
+				SELECT duration_ms,
+					(julianday(reviewed_at) - julianday(LAG(reviewed_at) OVER (ORDER BY reviewed_at, id))) * 86400 AS gap
+				FROM review_logs WHERE user_id = ? AND deck_id = ?
+			)`, maxStudyGap.Seconds(), g.UserID, deckID).
+		Scan(&stats.StudySeconds).Error
+	if err != nil {
+		return stats, err
+	}
+
+	err = g.reviewLogs().
+		Select("COALESCE(AVG(duration_ms), 0) / 1000.0").
+		Where("deck_id = ? AND duration_ms > 0", deckID).
+		Scan(&stats.AverageAnswerSeconds).Error
+
+	return stats, err
+}
+
+// Only the first answer to a review card each day counts, so retries after a
+// lapse in the same session don't inflate the rate.
+func trueRetention(logs []models.ReviewLog, boundary DayBoundary) RetentionStats {
+	type cardDay struct {
+		cardID uint
+		day    int
+	}
+	seen := map[cardDay]bool{}
+
+	var stats RetentionStats
+	for _, l := range logs {
+		if l.Stage != "review" {
+			continue
+		}
+		key := cardDay{l.CardID, boundary.dayNumber(l.ReviewedAt)}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		stats.Reviews++
+		if l.Correct {
+			stats.Correct++
+		}
+	}
+	if stats.Reviews > 0 {
+		stats.Rate = float64(stats.Correct) / float64(stats.Reviews)
+	}
+	return stats
+}
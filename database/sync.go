@@ -0,0 +1,451 @@
+package database
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+	"webproject/models"
+
+	"gorm.io/gorm"
+)
+
+// changes stored this long before a sync token are sent again, so a write
+// still in flight while that sync ran isn't lost. Clients apply changes by
+// id, getting one twice is harmless.
+const syncOverlap = time.Second
+
+var ErrInvalidSyncToken = errors.New("invalid sync token")
+
+// SyncCard is a card as an offline client last edited it
+type SyncCard struct {
+	ID        uint      `json:"id"`        // 0 for cards made offline
+	ClientID  string    `json:"client_id"` // names a new card until it has an id
+	DeckID    uint      `json:"deck_id"`
+	Question  string    `json:"question"`
+	Answer    string    `json:"answer"`
+	Extra     string    `json:"extra"`
+	Tags      []string  `json:"tags"` // null leaves the tags alone
+	UpdatedAt time.Time `json:"updated_at"`
+	Deleted   bool      `json:"deleted"`
+}
+
+// SyncReview is an answer given offline
+type SyncReview struct {
+	ClientID     string    `json:"client_id"`
+	CardID       uint      `json:"card_id"`
+	CardClientID string    `json:"card_client_id"` // for cards made offline
+	Answer       string    `json:"answer"`
+	Correct      bool      `json:"correct"`
+	DurationMs   int64     `json:"duration_ms"`
+	ReviewedAt   time.Time `json:"reviewed_at"`
+	Cram         bool      `json:"cram"`
+}
+
+type SyncRequest struct {
+	Token   string       `json:"token"`
+	Cards   []SyncCard   `json:"cards"`
+	Reviews []SyncReview `json:"reviews"`
+}
+
+// CardChange is a change a sync stored, so other devices can be told
+type CardChange struct {
+	Kind   string // created, updated, deleted or reviewed
+	DeckID uint
+	CardID uint
+}
+
+type SyncRejection struct {
+	CardID   uint   `json:"card_id,omitempty"`
+	ClientID string `json:"client_id,omitempty"`
+	Reason   string `json:"reason"`
+}
+
+// SyncResult holds what changed on the server since the client's token.
+// Cards come with the user's own progress.
+type SyncResult struct {
+	Token    string             `json:"token"`
+	Created  map[string]uint    `json:"created"` // client id to server id of new cards
+	Rejected []SyncRejection    `json:"rejected"`
+	Decks    []models.Deck      `json:"decks"`
+	Cards    []models.Card      `json:"cards"`
+	Deleted  []uint             `json:"deleted"`
+	Reviews  []models.ReviewLog `json:"reviews"`
+	Changes  []CardChange       `json:"-"`
+}
+
+// Sync merges an offline client's changes and returns everything that
+// changed since its last sync. Without a token everything the user can see
+// is returned and the client starts over from it.
+//
+// Conflicts are settled the same way on every device:
+//   - card content is last writer wins on updated_at, ties keep the server's
+//   - deleted cards stay deleted, edits to them are rejected, until the card
+//     is restored from the trash
+//   - reviews are a union, a review is only logged once per client id
+//   - the progress of every card that got reviews is replayed from its whole
+//     log in the order answers were given
+func (g *GormDB) Sync(request SyncRequest) (SyncResult, error) {
+	result := SyncResult{Created: map[string]uint{}, Rejected: []SyncRejection{}, Deleted: []uint{}}
+	since := int64(-1)
+	if request.Token != "" {
+		token, err := strconv.ParseInt(request.Token, 10, 64)
+		if err != nil {
+			return result, ErrInvalidSyncToken
+		}
+		since = token - int64(syncOverlap)
+	}
+
+	err := g.DB.Transaction(func(tx *gorm.DB) error {
+		t := *g
+		t.DB = tx
+
+		var resend []uint
+		for _, card := range request.Cards {
+			change, reason, err := t.mergeCard(card, result.Created)
+			if err != nil {
+				return err
+			}
+			if reason != "" {
+				result.Rejected = append(result.Rejected, SyncRejection{CardID: card.ID, ClientID: card.ClientID, Reason: reason})
+				resend = append(resend, card.ID)
+				continue
+			}
+			if card.ID == 0 && change.CardID != 0 {
+				result.Created[card.ClientID] = change.CardID
+			}
+			if change.Kind != "" {
+				result.Changes = append(result.Changes, change)
+			}
+		}
+
+		replay := map[uint]bool{}
+		for _, review := range request.Reviews {
+			change, reason, err := t.mergeReview(review, result.Created)
+			if err != nil {
+				return err
+			}
+			if reason != "" {
+				result.Rejected = append(result.Rejected, SyncRejection{CardID: review.CardID, ClientID: review.ClientID, Reason: reason})
+			} else if change.Kind != "" {
+				replay[change.CardID] = true
+				result.Changes = append(result.Changes, change)
+			}
+		}
+		for cardID := range replay {
+			if err := t.replayProgress(cardID); err != nil {
+				return err
+			}
+		}
+
+		result.Token = strconv.FormatInt(tx.NowFunc().UnixNano(), 10)
+		return t.syncChanges(since, resend, &result)
+	})
+	return result, err
+}
+
+// mergeCard applies one card change. It returns what was stored, which is
+// nothing when the client sent the change before, or why the change was
+// rejected.
+func (g *GormDB) mergeCard(change SyncCard, created map[string]uint) (CardChange, string, error) {
+	now := g.Now()
+	if change.UpdatedAt.IsZero() || change.UpdatedAt.After(now) {
+		change.UpdatedAt = now
+	}
+	change.Question = strings.TrimSpace(change.Question)
+	change.Answer = strings.TrimSpace(change.Answer)
+
+	var stored models.Card
+	resent := false
+	if change.ID != 0 {
+		found := g.DB.Limit(1).Find(&stored, change.ID)
+		if found.Error != nil {
+			return CardChange{}, "", found.Error
+		}
+		if found.RowsAffected == 0 {
+			var deleted int64
+			if err := g.DB.Model(&models.CardDeletion{}).Where("card_id = ?", change.ID).Count(&deleted).Error; err != nil {
+				return CardChange{}, "", err
+			}
+			if deleted > 0 {
+				return CardChange{}, "the card was deleted", nil
+			}
+			return CardChange{}, "card not found", nil
+		}
+	} else {
+		if change.ClientID == "" {
+			return CardChange{}, "new cards need a client_id", nil
+		}
+		if id, ok := created[change.ClientID]; ok {
+			return CardChange{CardID: id}, "", nil
+		}
+		// the client may not have heard back from an earlier sync
+		found := g.DB.Where("client_id = ? AND deck_id IN (?)", change.ClientID, g.decksWithRole("editor")).
+			Limit(1).Find(&stored)
+		if found.Error != nil {
+			return CardChange{}, "", found.Error
+		}
+		if found.RowsAffected == 0 {
+			if change.Deleted {
+				return CardChange{}, "", nil
+			}
+			return g.createSyncedCard(change)
+		}
+		resent = true
+	}
+
+	role, err := g.DeckRole(stored.DeckID)
+	if err != nil {
+		return CardChange{}, "", err
+	}
+	if role == "" {
+		return CardChange{}, "card not found", nil
+	}
+	if !RoleAllows(role, "editor") {
+		return CardChange{}, "editing the card needs the editor role", nil
+	}
+	if !change.UpdatedAt.After(stored.UpdatedAt) {
+		applied := change.UpdatedAt.Equal(stored.UpdatedAt) && change.Question == stored.Question &&
+			change.Answer == stored.Answer && change.Extra == stored.Extra
+		if resent || applied {
+			return CardChange{CardID: stored.ID}, "", nil
+		}
+		return CardChange{}, "the card was changed on the server since", nil
+	}
+
+	if change.Deleted {
+		return CardChange{"deleted", stored.DeckID, stored.ID}, "", g.DeleteCardByID(stored.ID)
+	}
+	if change.Question == "" || change.Answer == "" {
+		return CardChange{}, "question or answer can't be empty", nil
+	}
+	updates := map[string]any{
+		"question":   change.Question,
+		"answer":     change.Answer,
+		"extra":      change.Extra,
+		"updated_at": change.UpdatedAt,
+	}
+	if change.DeckID != 0 && change.DeckID != stored.DeckID {
+		if reason, err := g.syncTargetDeck(change.DeckID); err != nil || reason != "" {
+			return CardChange{}, reason, err
+		}
+		updates["deck_id"] = change.DeckID
+		stored.DeckID = change.DeckID
+	}
+	if err := g.DB.Model(&models.Card{ID: stored.ID}).Updates(updates).Error; err != nil {
+		return CardChange{}, "", err
+	}
+	if change.Tags != nil {
+		tags, err := findOrCreateTags(g.DB, change.Tags)
+		if err != nil {
+			return CardChange{}, "", err
+		}
+		if err := g.DB.Model(&models.Card{ID: stored.ID}).Association("Tags").Replace(tags); err != nil {
+			return CardChange{}, "", err
+		}
+	}
+	return CardChange{"updated", stored.DeckID, stored.ID}, "", nil
+}
+
+func (g *GormDB) createSyncedCard(change SyncCard) (CardChange, string, error) {
+	if reason, err := g.syncTargetDeck(change.DeckID); err != nil || reason != "" {
+		return CardChange{}, reason, err
+	}
+	if change.Question == "" || change.Answer == "" {
+		return CardChange{}, "question or answer can't be empty", nil
+	}
+	card, err := g.CreateCardWithTags(models.Card{
+		DeckID:      change.DeckID,
+		Question:    change.Question,
+		Answer:      change.Answer,
+		Extra:       change.Extra,
+		CardCreated: change.UpdatedAt,
+		UpdatedAt:   change.UpdatedAt,
+		ClientID:    change.ClientID,
+	}, change.Tags)
+	return CardChange{"created", card.DeckID, card.ID}, "", err
+}
+
+// syncTargetDeck says why cards can't be put in the deck, if they can't
+func (g *GormDB) syncTargetDeck(deckID uint) (string, error) {
+	role, err := g.DeckRole(deckID)
+	if err != nil {
+		return "", err
+	}
+	if !RoleAllows(role, "editor") {
+		return "adding cards to the deck needs the editor role", nil
+	}
+	deck, err := g.GetDeckByID(deckID)
+	if err != nil {
+		return "", err
+	}
+	if deck.Filter != "" {
+		return "cards can't be added to a filtered deck", nil
+	}
+	return "", nil
+}
+
+// mergeReview logs an offline answer unless it was logged before. It
+// returns the review of the card whose progress needs replaying, or why the
+// review was rejected.
+func (g *GormDB) mergeReview(review SyncReview, created map[string]uint) (CardChange, string, error) {
+	if review.ClientID == "" {
+		return CardChange{}, "reviews need a client_id", nil
+	}
+	cardID := review.CardID
+	if cardID == 0 {
+		cardID = created[review.CardClientID]
+	}
+
+	var logged int64
+	err := g.reviewLogs().Where("client_id = ?", review.ClientID).Count(&logged).Error
+	if err != nil || logged > 0 {
+		return CardChange{}, "", err
+	}
+
+	card, err := g.GetCardByID(cardID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return CardChange{}, "card not found", nil
+	}
+	if err != nil {
+		return CardChange{}, "", err
+	}
+	reschedule, err := g.reschedules(card)
+	if err != nil {
+		return CardChange{}, "", err
+	}
+	options, err := g.GetDeckOptions(homeDeckID(card))
+	if err != nil {
+		return CardChange{}, "", err
+	}
+
+	now := g.Now()
+	if review.ReviewedAt.IsZero() || review.ReviewedAt.After(now) {
+		review.ReviewedAt = now
+	}
+	event := newAnswerEvent(review.Answer, review.Correct,
+		time.Duration(review.DurationMs)*time.Millisecond, options, review.ReviewedAt)
+	event.cram = review.Cram || !reschedule
+
+	// the before and after fields are filled in by the replay
+	log := newReviewLog(card, card, event)
+	log.UserID = g.UserID
+	log.ClientID = review.ClientID
+	return CardChange{"reviewed", homeDeckID(card), card.ID}, "", g.DB.Create(log).Error
+}
+
+// replayProgress recomputes the user's progress on a card from its review
+// log, and the log's before and after fields along with it
+func (g *GormDB) replayProgress(cardID uint) error {
+	card, err := g.GetCardByID(cardID)
+	if err != nil {
+		return err
+	}
+	options, err := g.GetDeckOptions(homeDeckID(card))
+	if err != nil {
+		return err
+	}
+	var logs []models.ReviewLog
+	err = g.reviewLogs().Where("card_id = ?", cardID).Order("reviewed_at ASC, id ASC").Find(&logs).Error
+	if err != nil {
+		return err
+	}
+
+	state := card
+	state.Correct, state.Incorrect, state.Lapses = 0, 0, 0
+	state.LastReviewDate = time.Time{}
+	state.Stage = "learning"
+	state.Ease = 1
+	state.ReviewDueDate = card.CardCreated
+	state.Stability, state.Difficulty = 0, 0
+
+	for _, log := range logs {
+		before := state
+		event := answerEvent{
+			given:    log.Answer,
+			correct:  log.Correct,
+			grade:    log.Grade,
+			duration: time.Duration(log.DurationMs) * time.Millisecond,
+			at:       log.ReviewedAt,
+			cram:     log.Cram,
+		}
+		if !event.cram && state.Stage == "review" {
+			state = scheduleReview(state, event, options)
+		} else if !event.cram {
+			state = scheduleLearning(state, event, options)
+		}
+
+		replayed := newReviewLog(before, state, event)
+		if replayed.Stage == log.Stage && replayed.StageAfter == log.StageAfter &&
+			replayed.EaseBefore == log.EaseBefore && replayed.EaseAfter == log.EaseAfter &&
+			replayed.LastReviewBefore.Equal(log.LastReviewBefore) &&
+			replayed.DueBefore.Equal(log.DueBefore) && replayed.DueAfter.Equal(log.DueAfter) &&
+			replayed.StabilityBefore == log.StabilityBefore && replayed.StabilityAfter == log.StabilityAfter &&
+			replayed.DifficultyAfter == log.DifficultyAfter {
+			continue
+		}
+		err := g.DB.Model(&log).Select("stage", "stage_after", "ease_before", "ease_after",
+			"last_review_before", "due_before", "due_after", "stability_before", "stability_after",
+			"difficulty_after").Updates(replayed).Error
+		if err != nil {
+			return err
+		}
+	}
+	if err := g.saveProgress(g.DB, state); err != nil {
+		return err
+	}
+
+	// the latest answer is the one that could have met an assignment. Sync
+	// runs in one transaction, a failure here leaves the reviews unsynced.
+	for i := len(logs) - 1; i >= 0; i-- {
+		if !logs[i].Cram {
+			if !logs[i].Correct {
+				return nil
+			}
+			return g.recordCompletions(cardID, logs[i].ReviewedAt)
+		}
+	}
+	return nil
+}
+
+// syncChanges fills in what changed since the token, and the cards whose
+// changes were rejected so the client takes the server's version
+func (g *GormDB) syncChanges(since int64, resend []uint, result *SyncResult) error {
+	if err := g.DB.Scopes(g.visibleDecks).Order("id ASC").Find(&result.Decks).Error; err != nil {
+		return err
+	}
+
+	cards := g.cards().Preload("Tags").Order("cards.id ASC")
+	logs := g.reviewLogs().Order("reviewed_at ASC, id ASC")
+	if since >= 0 {
+		cards = cards.Where(`cards.id IN (SELECT id FROM cards WHERE synced_at > ?)
+			OR cards.id IN (SELECT card_id FROM card_progresses WHERE user_id = ? AND synced_at > ?)
+			OR cards.id IN ?`, since, g.UserID, since, append(resend, 0))
+		logs = logs.Where("synced_at > ?", since)
+
+		// decks the user can't see any more might have been deleted too
+		err := g.DB.Model(&models.CardDeletion{}).
+			Where("synced_at > ?", since).
+			Where("deck_id IN (?) OR deck_id NOT IN (SELECT id FROM decks WHERE deleted_at IS NULL)", g.decksWithRole("viewer")).
+			Order("card_id ASC").
+			Pluck("card_id", &result.Deleted).Error
+		if err != nil {
+			return err
+		}
+	}
+	if err := cards.Find(&result.Cards).Error; err != nil {
+		return err
+	}
+	return logs.Find(&result.Reviews).Error
+}
+
+func recordDeletions(tx *gorm.DB, deckID uint, cardIDs []uint, at time.Time) error {
+	if len(cardIDs) == 0 {
+		return nil
+	}
+	deletions := make([]models.CardDeletion, len(cardIDs))
+	for i, id := range cardIDs {
+		deletions[i] = models.CardDeletion{CardID: id, DeckID: deckID, DeletedAt: at}
+	}
+	return tx.Create(&deletions).Error
+}
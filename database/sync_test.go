@@ -0,0 +1,202 @@
+package database
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+	"webproject/models"
+
+	"gorm.io/gorm"
+)
+
+func TestSyncCardConflicts(t *testing.T) {
+	edited := testStart
+	tests := []struct {
+		name         string
+		deleteFirst  bool // the card was deleted on the server before the sync
+		change       SyncCard
+		rejected     string // part of the reason, empty when the change is stored
+		wantQuestion string // empty when the card should be gone
+	}{
+		{
+			name:         "newer edit wins",
+			change:       SyncCard{Question: "chat!", Answer: "cat", UpdatedAt: edited.Add(time.Hour)},
+			wantQuestion: "chat!",
+		},
+		{
+			name:         "older edit loses",
+			change:       SyncCard{Question: "chat!", Answer: "cat", UpdatedAt: edited.Add(-time.Hour)},
+			rejected:     "changed on the server",
+			wantQuestion: "chat",
+		},
+		{
+			name:         "tie keeps the server's",
+			change:       SyncCard{Question: "chat!", Answer: "cat", UpdatedAt: edited},
+			rejected:     "changed on the server",
+			wantQuestion: "chat",
+		},
+		{
+			name:         "tie with the same content was applied before",
+			change:       SyncCard{Question: "chat", Answer: "chat answer", UpdatedAt: edited},
+			wantQuestion: "chat",
+		},
+		{
+			name:   "newer delete wins",
+			change: SyncCard{Deleted: true, UpdatedAt: edited.Add(time.Hour)},
+		},
+		{
+			name:         "older delete loses to the edit",
+			change:       SyncCard{Deleted: true, UpdatedAt: edited.Add(-time.Hour)},
+			rejected:     "changed on the server",
+			wantQuestion: "chat",
+		},
+		{
+			name:        "edit to a deleted card",
+			deleteFirst: true,
+			change:      SyncCard{Question: "chat!", Answer: "cat", UpdatedAt: edited.Add(time.Hour)},
+			rejected:    "was deleted",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, clk := newTestDB(t)
+			_, cards := newTestDeck(t, g, "French", nil, "chat")
+			card := cards[0]
+			if tt.deleteFirst {
+				if err := g.DeleteCardByID(card.ID); err != nil {
+					t.Fatal(err)
+				}
+			}
+			clk.Set(edited.Add(2 * time.Hour))
+
+			tt.change.ID = card.ID
+			result, err := g.Sync(SyncRequest{Cards: []SyncCard{tt.change}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			switch {
+			case tt.rejected == "" && len(result.Rejected) > 0:
+				t.Errorf("rejected: %s", result.Rejected[0].Reason)
+			case tt.rejected != "" && (len(result.Rejected) != 1 || !strings.Contains(result.Rejected[0].Reason, tt.rejected)):
+				t.Errorf("rejections = %+v, want one saying %q", result.Rejected, tt.rejected)
+			}
+
+			stored, err := g.GetCardByID(card.ID)
+			if tt.wantQuestion == "" {
+				if !errors.Is(err, gorm.ErrRecordNotFound) {
+					t.Errorf("card still there: %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stored.Question != tt.wantQuestion {
+				t.Errorf("question = %q, want %q", stored.Question, tt.wantQuestion)
+			}
+		})
+	}
+}
+
+func TestSyncLogsAReviewOncePerClientID(t *testing.T) {
+	g, clk := newTestDB(t)
+	_, cards := newTestDeck(t, g, "French", nil, "chat")
+	clk.Set(testStart.Add(time.Hour))
+
+	review := SyncReview{ClientID: "phone-1", CardID: cards[0].ID, Answer: "cat", Correct: true,
+		ReviewedAt: testStart.Add(time.Minute)}
+	requests := []SyncRequest{
+		{Reviews: []SyncReview{review, review}},
+		// the client didn't hear back and sends it again
+		{Reviews: []SyncReview{review}},
+	}
+	for _, request := range requests {
+		if _, err := g.Sync(request); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var logged int64
+	if err := g.reviewLogs().Where("client_id = ?", review.ClientID).Count(&logged).Error; err != nil {
+		t.Fatal(err)
+	}
+	card, err := g.GetCardByID(cards[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if logged != 1 || card.Correct != 1 {
+		t.Errorf("logged %d times and counted %d correct, want once", logged, card.Correct)
+	}
+}
+
+func TestSyncReplaysReviewsInTheOrderTheyWereGiven(t *testing.T) {
+	reviews := []SyncReview{
+		{ClientID: "r1", Answer: "x", Correct: false, ReviewedAt: testStart.Add(1 * time.Minute)},
+		{ClientID: "r2", Answer: "cat", Correct: true, ReviewedAt: testStart.Add(2 * time.Minute)},
+		{ClientID: "r3", Answer: "cat", Correct: true, ReviewedAt: testStart.Add(3 * time.Minute)},
+	}
+	deliveries := []struct {
+		name  string
+		syncs [][]int // indexes into reviews, one slice per sync
+	}{
+		{"all at once", [][]int{{0, 1, 2}}},
+		{"reversed", [][]int{{2, 1, 0}}},
+		{"the first one last", [][]int{{1, 2}, {0}}},
+		{"one per sync backwards", [][]int{{2}, {1}, {0}}},
+	}
+
+	var results []models.Card
+	for _, delivery := range deliveries {
+		t.Run(delivery.name, func(t *testing.T) {
+			g, clk := newTestDB(t)
+			_, cards := newTestDeck(t, g, "French", nil, "chat")
+			clk.Set(testStart.Add(time.Hour))
+			for _, sync := range delivery.syncs {
+				var request SyncRequest
+				for _, i := range sync {
+					review := reviews[i]
+					review.CardID = cards[0].ID
+					request.Reviews = append(request.Reviews, review)
+				}
+				if _, err := g.Sync(request); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			card, err := g.GetCardByID(cards[0].ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if card.Stage != "review" || card.Correct != 2 || card.Incorrect != 1 {
+				t.Errorf("stage %s with %d correct and %d incorrect, want review with 2 and 1",
+					card.Stage, card.Correct, card.Incorrect)
+			}
+			if !card.LastReviewDate.Equal(reviews[2].ReviewedAt) {
+				t.Errorf("last reviewed %v, want the latest answer's %v", card.LastReviewDate, reviews[2].ReviewedAt)
+			}
+
+			var logs []models.ReviewLog
+			if err := g.reviewLogs().Order("reviewed_at ASC").Find(&logs).Error; err != nil {
+				t.Fatal(err)
+			}
+			stages := []string{}
+			for _, log := range logs {
+				stages = append(stages, log.Stage+">"+log.StageAfter)
+			}
+			if got := strings.Join(stages, " "); got != "learning>learning learning>learning learning>review" {
+				t.Errorf("logged stages %s", got)
+			}
+			results = append(results, card)
+		})
+	}
+
+	for i, card := range results[1:] {
+		first := results[0]
+		if card.Ease != first.Ease || card.Stability != first.Stability || card.Difficulty != first.Difficulty ||
+			!card.ReviewDueDate.Equal(first.ReviewDueDate) {
+			t.Errorf("%s ended up with %+v, %s with %+v", deliveries[i+1].name, card, deliveries[0].name, first)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package database
+
+import (
+	"strings"
+	"time"
+	"webproject/models"
+
+	"gorm.io/gorm"
+)
+
+type TagCount struct {
+	Name  string `json:"name"`
+	Cards int64  `json:"cards"`
+}
+
+// NormalizeTags lower-cases tags and splits on whitespace, so "Food A1" is
+// two tags like in Anki. Duplicates are dropped.
+func NormalizeTags(raw []string) []string {
+	seen := map[string]bool{}
+	var tags []string
+	for _, r := range raw {
+		for _, tag := range strings.Fields(strings.ToLower(r)) {
+			if !seen[tag] {
+				seen[tag] = true
+				tags = append(tags, tag)
+			}
+		}
+	}
+	return tags
+}
+
+// withTags keeps only cards that have every one of the given tags
+func withTags(tags []string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		for _, tag := range NormalizeTags(tags) {
+			db = db.Where(`cards.id IN (SELECT card_tags.card_id FROM card_tags
+				JOIN tags ON tags.id = card_tags.tag_id WHERE tags.name = ?)`, tag)
+		}
+		return db
+	}
+}
+
+func findOrCreateTags(tx *gorm.DB, names []string) ([]models.Tag, error) {
+	tags := make([]models.Tag, 0, len(names))
+	for _, name := range NormalizeTags(names) {
+		tag := models.Tag{Name: name}
+		if err := tx.Where(tag).FirstOrCreate(&tag).Error; err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
+
+func (g *GormDB) CreateCardWithTags(card models.Card, tags []string) (models.Card, error) {
+	err := g.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if card.Tags, err = findOrCreateTags(tx, tags); err != nil {
+			return err
+		}
+		return tx.Create(&card).Error
+	})
+	return card, err
+}
+
+func (g *GormDB) AddTagsToCards(cardIDs []uint, names []string) error {
+	return g.DB.Transaction(func(tx *gorm.DB) error {
+		tags, err := findOrCreateTags(tx, names)
+		if err != nil || len(tags) == 0 {
+			return err
+		}
+		for _, id := range cardIDs {
+			if err := tx.Model(&models.Card{ID: id}).Association("Tags").Append(tags); err != nil {
+				return err
+			}
+		}
+		return touchCards(tx, cardIDs, g.Now())
+	})
+}
+
+func (g *GormDB) RemoveTagsFromCards(cardIDs []uint, names []string) error {
+	names = NormalizeTags(names)
+	if len(cardIDs) == 0 || len(names) == 0 {
+		return nil
+	}
+	return g.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Exec(`DELETE FROM card_tags WHERE card_id IN ?
+			AND tag_id IN (SELECT id FROM tags WHERE name IN ?)`, cardIDs, names).Error
+		if err != nil {
+			return err
+		}
+		return touchCards(tx, cardIDs, g.Now())
+	})
+}
+
+// touchCards marks the cards' content as edited, tags count as content
+func touchCards(tx *gorm.DB, cardIDs []uint, at time.Time) error {
+	return tx.Model(&models.Card{}).Where("id IN ?", cardIDs).Update("updated_at", at).Error
+}
+
+// GetTags counts the tags on cards the user can open
+func (g *GormDB) GetTags() ([]TagCount, error) {
+	tags := []TagCount{}
+	err := g.DB.Model(&models.Tag{}).
+		Select("tags.name AS name, COUNT(card_tags.card_id) AS cards").
+		Joins("JOIN card_tags ON card_tags.tag_id = tags.id").
+		Where("card_tags.card_id IN (?)", g.cards().Select("cards.id")).
+		Group("tags.id").
+		Order("tags.name ASC").
+		Scan(&tags).Error
+	return tags, err
+}
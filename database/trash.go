@@ -0,0 +1,221 @@
+package database
+
+import (
+	"errors"
+	"time"
+	"webproject/models"
+
+	"gorm.io/gorm"
+)
+
+// TrashRetention is how long deleted cards and decks can be restored before
+// PurgeTrash deletes them for good
+const TrashRetention = 30 * 24 * time.Hour
+
+var ErrNotInTrash = errors.New("not in the trash")
+
+type TrashedDeck struct {
+	ID        uint      `json:"id"`
+	Name      string    `json:"name"`
+	ParentID  *uint     `json:"parent_id"`
+	Cards     int64     `json:"cards"` // deleted with the deck, restored with it
+	DeletedAt time.Time `json:"deleted_at"`
+	PurgeAt   time.Time `json:"purge_at"`
+}
+
+type TrashedCard struct {
+	ID        uint      `json:"id"`
+	DeckID    uint      `json:"deck_id"`
+	DeckName  string    `json:"deck_name"`
+	Question  string    `json:"question"`
+	Answer    string    `json:"answer"`
+	DeletedAt time.Time `json:"deleted_at"`
+	PurgeAt   time.Time `json:"purge_at"`
+}
+
+type Trash struct {
+	Decks []TrashedDeck `json:"decks"`
+	Cards []TrashedCard `json:"cards"`
+}
+
+// trashedDecks are the deleted decks the user could delete, which takes the
+// owner role
+func (g *GormDB) trashedDecks() *gorm.DB {
+	return g.DB.Unscoped().Model(&models.Deck{}).
+		Where("decks.deleted_at IS NOT NULL").
+		Where("decks.owner_id = ? OR decks.parent_id IN (?)", g.UserID, g.decksWithRole("owner"))
+}
+
+// trashedCards are the deleted cards of decks the user can edit. Cards of a
+// deck in the trash come back with the deck.
+func (g *GormDB) trashedCards() *gorm.DB {
+	return g.DB.Unscoped().Model(&models.Card{}).
+		Where("cards.deleted_at IS NOT NULL AND cards.deck_id IN (?)", g.decksWithRole("editor"))
+}
+
+// GetTrash lists what the user can restore, most recently deleted first
+func (g *GormDB) GetTrash() (Trash, error) {
+	trash := Trash{Decks: []TrashedDeck{}, Cards: []TrashedCard{}}
+	err := g.trashedDecks().
+		Select(`decks.id, decks.name, decks.parent_id, decks.deleted_at,
+			(SELECT COUNT(*) FROM cards WHERE cards.deck_id = decks.id AND cards.deleted_at = decks.deleted_at) AS cards`).
+		Order("decks.deleted_at DESC, decks.id DESC").
+		Scan(&trash.Decks).Error
+	if err != nil {
+		return trash, err
+	}
+	err = g.trashedCards().
+		Select("cards.id, cards.deck_id, decks.name AS deck_name, cards.question, cards.answer, cards.deleted_at").
+		Joins("JOIN decks ON decks.id = cards.deck_id").
+		Order("cards.deleted_at DESC, cards.id DESC").
+		Scan(&trash.Cards).Error
+
+	for i := range trash.Decks {
+		trash.Decks[i].PurgeAt = trash.Decks[i].DeletedAt.Add(TrashRetention)
+	}
+	for i := range trash.Cards {
+		trash.Cards[i].PurgeAt = trash.Cards[i].DeletedAt.Add(TrashRetention)
+	}
+	return trash, err
+}
+
+func (g *GormDB) RestoreCard(id uint) (models.Card, error) {
+	var trashed int64
+	if err := g.trashedCards().Where("cards.id = ?", id).Count(&trashed).Error; err != nil {
+		return models.Card{}, err
+	}
+	if trashed == 0 {
+		return models.Card{}, ErrNotInTrash
+	}
+
+	err := g.DB.Transaction(func(tx *gorm.DB) error {
+		return restoreCards(tx, []uint{id}, g.Now())
+	})
+	if err != nil {
+		return models.Card{}, err
+	}
+	return g.GetCardByID(id)
+}
+
+// RestoreDeck brings back the deck with the cards deleted along with it. It
+// goes back under its parent unless that is gone too, then it becomes a top
+// level deck. Subdecks that moved up when it was deleted stay where they are.
+func (g *GormDB) RestoreDeck(id uint) (models.Deck, error) {
+	var deck models.Deck
+	found := g.trashedDecks().Where("decks.id = ?", id).Limit(1).Find(&deck)
+	if found.Error != nil {
+		return deck, found.Error
+	}
+	if found.RowsAffected == 0 {
+		return deck, ErrNotInTrash
+	}
+
+	err := g.DB.Transaction(func(tx *gorm.DB) error {
+		var cardIDs []uint
+		err := tx.Unscoped().Model(&models.Card{}).
+			Where("deck_id = ? AND deleted_at = ?", deck.ID, deck.DeletedAt).
+			Pluck("id", &cardIDs).Error
+		if err != nil {
+			return err
+		}
+		if err := restoreCards(tx, cardIDs, g.Now()); err != nil {
+			return err
+		}
+
+		updates := map[string]any{"deleted_at": nil}
+		if deck.ParentID != nil {
+			var parents int64
+			if err := tx.Model(&models.Deck{}).Where("id = ?", *deck.ParentID).Count(&parents).Error; err != nil {
+				return err
+			}
+			if parents == 0 {
+				updates["parent_id"] = nil
+				deck.ParentID = nil
+			}
+		}
+		return tx.Unscoped().Model(&models.Deck{}).Where("id = ?", deck.ID).Updates(updates).Error
+	})
+	deck.DeletedAt = gorm.DeletedAt{}
+	return deck, err
+}
+
+// restoreCards takes cards out of the trash. Restoring counts as an edit, so
+// syncing clients that dropped the cards get them back.
+func restoreCards(tx *gorm.DB, cardIDs []uint, at time.Time) error {
+	if len(cardIDs) == 0 {
+		return nil
+	}
+	if err := tx.Where("card_id IN ?", cardIDs).Delete(&models.CardDeletion{}).Error; err != nil {
+		return err
+	}
+	return tx.Unscoped().Model(&models.Card{}).Where("id IN ?", cardIDs).
+		Updates(map[string]any{"deleted_at": nil, "updated_at": at}).Error
+}
+
+// PurgeTrash deletes the cards and decks of every user that went to the
+// trash before the given time, returning how many of each, along with their
+// review logs, options and assignments. The deletions that tell syncing
+// clients the cards are gone are kept.
+func (g *GormDB) PurgeTrash(before time.Time) (int64, int64, error) {
+	var deckIDs, cardIDs []uint
+	err := g.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Model(&models.Deck{}).Where("deleted_at < ?", before).Pluck("id", &deckIDs).Error; err != nil {
+			return err
+		}
+		err := tx.Unscoped().Model(&models.Card{}).
+			Where("deleted_at < ? OR deck_id IN ?", before, append(deckIDs, 0)).
+			Pluck("id", &cardIDs).Error
+		if err != nil {
+			return err
+		}
+
+		if len(cardIDs) > 0 {
+			if err := tx.Where("card_id IN ?", cardIDs).Delete(&models.CardProgress{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Exec("DELETE FROM card_tags WHERE card_id IN ?", cardIDs).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("card_id IN ?", cardIDs).Delete(&models.ReviewLog{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Unscoped().Where("id IN ?", cardIDs).Delete(&models.Card{}).Error; err != nil {
+				return err
+			}
+		}
+		if len(deckIDs) == 0 {
+			return nil
+		}
+
+		// decks deleted before their parent lose it for good
+		err = tx.Unscoped().Model(&models.Deck{}).Where("parent_id IN ?", deckIDs).
+			Update("parent_id", nil).Error
+		if err != nil {
+			return err
+		}
+		if err := tx.Where("deck_id IN ?", deckIDs).Delete(&models.DeckMember{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("deck_id IN ?", deckIDs).Delete(&models.DeckInvite{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("deck_id IN ?", deckIDs).Delete(&models.ClassDeck{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("deck_id IN ?", deckIDs).Delete(&models.DeckOptions{}).Error; err != nil {
+			return err
+		}
+		assignments := tx.Model(&models.Assignment{}).Where("deck_id IN ?", deckIDs).Select("id")
+		if err := tx.Where("assignment_id IN (?)", assignments).Delete(&models.AssignmentCompletion{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("deck_id IN ?", deckIDs).Delete(&models.Assignment{}).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Where("id IN ?", deckIDs).Delete(&models.Deck{}).Error
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	return int64(len(cardIDs)), int64(len(deckIDs)), nil
+}
@@ -0,0 +1,57 @@
+package database
+
+import (
+	"testing"
+	"time"
+	"webproject/models"
+)
+
+func TestPurgeTrashLeavesNothingOfTheDeckBehind(t *testing.T) {
+	alice, bob, clk, card, assignment := newTestAssignment(t)
+	kept, keptCards := newTestDeck(t, alice, "Spanish", nil, "gato")
+	learn(t, bob, card)
+	learn(t, alice, keptCards[0])
+	for _, deckID := range []uint{card.DeckID, kept.ID} {
+		if err := alice.SaveDeckOptions(models.DeckOptions{DeckID: deckID, LearningLimit: 5}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := alice.DeleteDeckByID(card.DeckID); err != nil {
+		t.Fatal(err)
+	}
+	clk.Set(testStart.Add(time.Hour))
+	cards, decks, err := alice.PurgeTrash(clk.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cards != 1 || decks != 1 {
+		t.Fatalf("purged %d cards and %d decks, want 1 and 1", cards, decks)
+	}
+
+	tests := []struct {
+		name  string
+		model any
+		where string
+		id    uint
+		want  int64
+	}{
+		{"review logs", &models.ReviewLog{}, "card_id = ?", card.ID, 0},
+		{"deck options", &models.DeckOptions{}, "deck_id = ?", card.DeckID, 0},
+		{"assignments", &models.Assignment{}, "deck_id = ?", card.DeckID, 0},
+		{"completions", &models.AssignmentCompletion{}, "assignment_id = ?", assignment.ID, 0},
+		{"other deck's review logs", &models.ReviewLog{}, "card_id = ?", keptCards[0].ID, 2},
+		{"other deck's options", &models.DeckOptions{}, "deck_id = ?", kept.ID, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var count int64
+			if err := alice.DB.Model(tt.model).Where(tt.where, tt.id).Count(&count).Error; err != nil {
+				t.Fatal(err)
+			}
+			if count != tt.want {
+				t.Errorf("%d rows left, want %d", count, tt.want)
+			}
+		})
+	}
+}
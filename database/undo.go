@@ -0,0 +1,142 @@
+package database
+
+import (
+	"errors"
+	"time"
+	"webproject/models"
+
+	"gorm.io/gorm"
+)
+
+var ErrNothingToUndo = errors.New("no answer to undo")
+
+// UndoLastAnswer takes back the user's latest answer to a card of the deck or
+// its subdecks. The card gets the scheduling it had before, read from the
+// answer's review log entry, which is deleted along with the assignment
+// completions the answer recorded. Answering again and undoing again steps
+// further back.
+func (g *GormDB) UndoLastAnswer(deckID uint) (models.Card, models.ReviewLog, error) {
+	var log models.ReviewLog
+	ids, err := g.GetDeckSubtreeIDs(deckID)
+	if err != nil {
+		return models.Card{}, log, err
+	}
+
+	// cards borrowed by a filtered deck are logged under their home deck,
+	// with the filtered deck alongside as the answer may have sent them home
+	found := g.reviewLogs().
+		Where("card_id IN (?)", g.cards().Select("cards.id")).
+		Where("deck_id IN ? OR filtered_deck_id IN ? OR card_id IN (?)",
+			ids, ids, g.cards().Where("cards.deck_id IN ?", ids).Select("cards.id")).
+		Order("reviewed_at DESC, id DESC").
+		Limit(1).
+		Find(&log)
+	if found.Error != nil {
+		return models.Card{}, log, found.Error
+	}
+	if found.RowsAffected == 0 {
+		return models.Card{}, log, ErrNothingToUndo
+	}
+
+	card, err := g.GetCardByID(log.CardID)
+	if err != nil {
+		return card, log, err
+	}
+	// cram answers never changed the scheduling
+	var previous models.ReviewLog
+	if !log.Cram {
+		err := g.reviewLogs().
+			Where("card_id = ? AND cram = ? AND id != ?", log.CardID, false, log.ID).
+			Where("reviewed_at < ? OR (reviewed_at = ? AND id < ?)", log.ReviewedAt, log.ReviewedAt, log.ID).
+			Order("reviewed_at DESC, id DESC").
+			Limit(1).
+			Find(&previous).Error
+		if err != nil {
+			return card, log, err
+		}
+		card = scheduleBefore(card, log, previous, g.Now())
+	}
+	borrowed, err := g.borrowBack(&card, log)
+	if err != nil {
+		return card, log, err
+	}
+
+	err = g.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&log).Error; err != nil {
+			return err
+		}
+		if !log.Cram || borrowed {
+			if err := g.saveProgress(tx, card); err != nil {
+				return err
+			}
+		}
+		if log.Cram {
+			return nil
+		}
+
+		// assignments the answer met are open again, unless the answer
+		// before it meets them too
+		err := tx.Where("user_id = ? AND completed_at = ?", g.UserID, log.ReviewedAt).
+			Delete(&models.AssignmentCompletion{}).Error
+		if err != nil || previous.ID == 0 || !previous.Correct {
+			return err
+		}
+		t := *g
+		t.DB = tx
+		return t.recordCompletions(card.ID, previous.ReviewedAt)
+	})
+	return card, log, err
+}
+
+// borrowBack puts a card the answer sent home back into the filtered deck it
+// was answered in. It reports whether the card moved, which it doesn't when
+// the filtered deck is gone or another one borrowed the card since.
+func (g *GormDB) borrowBack(card *models.Card, log models.ReviewLog) (bool, error) {
+	if log.FilteredDeckID == nil || card.HomeDeckID != nil {
+		return false, nil
+	}
+	if _, err := g.GetDeckByID(*log.FilteredDeckID); errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	home := card.DeckID
+	card.HomeDeckID = &home
+	card.DeckID = *log.FilteredDeckID
+	return true, nil
+}
+
+// scheduleBefore puts the card back the way it was before the logged answer.
+// The log doesn't keep the difficulty before, that is what the previous
+// answer left.
+func scheduleBefore(card models.Card, log models.ReviewLog, previous models.ReviewLog, now time.Time) models.Card {
+	if log.Stage != "" {
+		card.Stage = log.Stage
+	}
+	card.Ease = log.EaseBefore
+	card.LastReviewDate = log.LastReviewBefore
+	card.ReviewDueDate = log.DueBefore
+	// entries from before due dates were logged, the card is due again now
+	if log.DueBefore.IsZero() {
+		card.ReviewDueDate = now
+	}
+	card.Stability = log.StabilityBefore
+	switch {
+	case previous.ID != 0:
+		card.Difficulty = previous.DifficultyAfter
+	case log.StabilityBefore == 0:
+		card.Difficulty = 0
+	}
+
+	if log.Correct && card.Correct > 0 {
+		card.Correct--
+	} else if !log.Correct && card.Incorrect > 0 {
+		card.Incorrect--
+	}
+	// a wrong answer to a review lapses the card, unless it was already
+	// relearning
+	if !log.Correct && log.Stage == "review" && log.EaseBefore != 1 && card.Lapses > 0 {
+		card.Lapses--
+	}
+	return card
+}
@@ -0,0 +1,109 @@
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestUndoPutsTheCardBackInTheFilteredDeck(t *testing.T) {
+	tests := []struct {
+		name       string
+		reschedule bool
+		wantStage  string
+	}{
+		// a correct review sends the card home
+		{"rescheduling", true, "review"},
+		// answered without rescheduling, logged as cram
+		{"not rescheduling", false, "learning"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, clk := newTestDB(t)
+			deck, cards := newTestDeck(t, g, "French", nil, "chat")
+			card := cards[0]
+			if tt.reschedule {
+				learn(t, g, card)
+			}
+			filtered, pulled, err := g.CreateFilteredDeck("Cram", "deck:French", 10, tt.reschedule)
+			if err != nil || pulled != 1 {
+				t.Fatalf("pulled %d cards: %v", pulled, err)
+			}
+
+			clk.Set(testStart.Add(time.Hour))
+			before, err := g.GetCardByID(card.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.reschedule {
+				err = g.UpdateReviewCardByID(card.ID, card.Answer, true, 0)
+			} else {
+				_, err = g.UpdateLearningCardByID(card.ID, card.Answer, true, 0)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if answered, err := g.GetCardByID(card.ID); err != nil || answered.DeckID != deck.ID {
+				t.Fatalf("the answer didn't send the card home: %+v %v", answered, err)
+			}
+
+			undone, log, err := g.UndoLastAnswer(filtered.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if log.Cram == tt.reschedule {
+				t.Errorf("undid a log with cram %v", log.Cram)
+			}
+			got, err := g.GetCardByID(card.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, c := range []struct {
+				what string
+				deck uint
+				home *uint
+			}{{"returned", undone.DeckID, undone.HomeDeckID}, {"stored", got.DeckID, got.HomeDeckID}} {
+				if c.deck != filtered.ID || c.home == nil || *c.home != deck.ID {
+					t.Errorf("%s card is in deck %d from %v, want %d from %d", c.what, c.deck, c.home, filtered.ID, deck.ID)
+				}
+			}
+			if got.Stage != tt.wantStage || !got.ReviewDueDate.Equal(before.ReviewDueDate) || got.Correct != before.Correct {
+				t.Errorf("card is %+v, want the scheduling of %+v", got, before)
+			}
+		})
+	}
+}
+
+func TestUndoTakesBackTheAssignmentCompletion(t *testing.T) {
+	_, bob, clk, card, assignment := newTestAssignment(t)
+	boundary := DayBoundary{Location: time.UTC}
+	status := func() string {
+		t.Helper()
+		progress, err := bob.AssignmentProgress(assignment, boundary)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return progress.Status
+	}
+
+	clk.Set(testStart.Add(time.Hour))
+	learn(t, bob, card)
+	if got := status(); got != "completed" {
+		t.Fatalf("status after learning = %s, want completed", got)
+	}
+
+	if _, _, err := bob.UndoLastAnswer(card.DeckID); err != nil {
+		t.Fatal(err)
+	}
+	if got := status(); got != "open" {
+		t.Errorf("status after undo = %s, want open", got)
+	}
+
+	// learned again after the deadline, it is late now
+	clk.Set(testStart.Add(72 * time.Hour))
+	if _, err := bob.UpdateLearningCardByID(card.ID, card.Answer, true, 0); err != nil {
+		t.Fatal(err)
+	}
+	if got := status(); got != "late" {
+		t.Errorf("status after answering again = %s, want late", got)
+	}
+}
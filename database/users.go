@@ -0,0 +1,155 @@
+package database
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+	"webproject/models"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const sessionTTL = 30 * 24 * time.Hour
+
+var (
+	ErrUsernameTaken = errors.New("username is already taken")
+	ErrInvalidLogin  = errors.New("invalid username or password")
+)
+
+// compared against when the username doesn't exist, so a login takes as long
+// either way
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy password"), bcrypt.DefaultCost)
+
+// CreateUser registers a new account
+func (g *GormDB) CreateUser(username string, password string) (models.User, error) {
+	user := models.User{Username: strings.TrimSpace(username), CreatedAt: g.Now()}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return user, err
+	}
+	user.PasswordHash = string(hash)
+
+	err = g.DB.Transaction(func(tx *gorm.DB) error {
+		var taken int64
+		if err := tx.Model(&models.User{}).Where("username = ?", user.Username).Count(&taken).Error; err != nil {
+			return err
+		}
+		if taken > 0 {
+			return ErrUsernameTaken
+		}
+		return tx.Create(&user).Error
+	})
+	return user, err
+}
+
+// MakeAdmin makes the account an admin of the server and hands it the
+// progress, review log, preferences and decks of user 0, so a database
+// studied before accounts existed keeps its history. The server does this
+// when started with -admin, registering never does, so whoever signs up
+// first doesn't get them.
+func MakeAdmin(db *gorm.DB, username string) (models.User, error) {
+	var user models.User
+	err := db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("username = ?", strings.TrimSpace(username)).First(&user).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrUserNotFound
+		}
+		if err != nil {
+			return err
+		}
+		user.Admin = true
+		if err := tx.Model(&user).Update("admin", true).Error; err != nil {
+			return err
+		}
+
+		for _, model := range []any{&models.CardProgress{}, &models.ReviewLog{}, &models.DeckOptions{}, &models.Settings{}} {
+			if err := tx.Model(model).Where("user_id = ?", 0).Update("user_id", user.ID).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(&models.Deck{}).Where("owner_id = ?", 0).Update("owner_id", user.ID).Error
+	})
+	return user, err
+}
+
+// DefaultUserID is who the command line tools work as unless told
+// otherwise: the admin, who took over user 0's history, and user 0 until
+// there is one
+func DefaultUserID(db *gorm.DB) (uint, error) {
+	var admin models.User
+	err := db.Where("admin = ?", true).Order("id ASC").Limit(1).Find(&admin).Error
+	return admin.ID, err
+}
+
+// Authenticate checks a username and password and returns the account
+func (g *GormDB) Authenticate(username string, password string) (models.User, error) {
+	var user models.User
+	err := g.DB.Where("username = ?", strings.TrimSpace(username)).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return user, ErrInvalidLogin
+	}
+	if err != nil {
+		return user, err
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) != nil {
+		return user, ErrInvalidLogin
+	}
+	return user, nil
+}
+
+// only a hash of session and API tokens is stored, so a leaked database
+// can't be used to log in
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func newToken() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// CreateSession starts a session for the user and returns its token
+func (g *GormDB) CreateSession(userID uint) (string, models.Session, error) {
+	token, err := newToken()
+	if err != nil {
+		return "", models.Session{}, err
+	}
+
+	now := g.Now()
+	session := models.Session{
+		UserID:    userID,
+		TokenHash: hashToken(token),
+		CreatedAt: now,
+		ExpiresAt: now.Add(sessionTTL),
+	}
+	err = g.DB.Create(&session).Error
+	return token, session, err
+}
+
+// GetSessionUser returns the user a session token belongs to, as long as the
+// session hasn't expired
+func (g *GormDB) GetSessionUser(token string) (models.User, error) {
+	var session models.Session
+	err := g.DB.Where("token_hash = ? AND expires_at > ?", hashToken(token), g.Now()).First(&session).Error
+	if err != nil {
+		return models.User{}, err
+	}
+
+	var user models.User
+	err = g.DB.First(&user, session.UserID).Error
+	return user, err
+}
+
+func (g *GormDB) DeleteSession(token string) error {
+	return g.DB.Where("token_hash = ?", hashToken(token)).Delete(&models.Session{}).Error
+}
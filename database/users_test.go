@@ -0,0 +1,46 @@
+package database
+
+import (
+	"errors"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+func TestOnlyTheNamedAdminGetsTheHistoryFromBeforeAccounts(t *testing.T) {
+	g, _ := newTestDB(t)
+	legacy := g.ForUser(0)
+	deck, _ := newTestDeck(t, legacy, "French", nil, "chat")
+
+	stranger, err := legacy.CreateUser("stranger", "password1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := legacy.CreateUser("alice", "password1"); err != nil {
+		t.Fatal(err)
+	}
+	if stranger.Admin {
+		t.Error("the first account to register is an admin")
+	}
+	if _, err := g.ForUser(stranger.ID).GetDeckByID(deck.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("the first account to register got user 0's deck: %v", err)
+	}
+	if id, err := DefaultUserID(g.DB); err != nil || id != 0 {
+		t.Fatalf("DefaultUserID without an admin = %d, %v, want 0", id, err)
+	}
+
+	if _, err := MakeAdmin(g.DB, "nobody"); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("MakeAdmin of a missing account = %v, want ErrUserNotFound", err)
+	}
+	alice, err := MakeAdmin(g.DB, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := DefaultUserID(g.DB)
+	if err != nil || id != alice.ID {
+		t.Fatalf("DefaultUserID = %d, %v, want alice's %d", id, err, alice.ID)
+	}
+	if _, err := g.ForUser(id).GetDeckByID(deck.ID); err != nil {
+		t.Errorf("the admin can't see user 0's old deck: %v", err)
+	}
+}
@@ -1,7 +1,16 @@
+// The server. Build it with
+//
+//	go build -tags sqlite_fts5
+//
+// so card search can use sqlite's full text index.
 package main
 
 import (
+	"errors"
+	"flag"
 	"log"
+	"time"
+	"webproject/clock"
 	"webproject/database"
 	"webproject/routes"
 
@@ -30,26 +39,53 @@ type Database interface {
 }
 
 func main() {
-	db, err := gorm.Open(sqlite.Open("test.db"), &gorm.Config{})
+	dbPath := flag.String("db", "test.db", "database file")
+	backupDir := flag.String("backups", "backups", "directory to keep database backups in")
+	backupEvery := flag.Duration("backup-every", 24*time.Hour, "how often to back up the database, 0 never does")
+	backupKeep := flag.Int("backup-keep", 7, "number of backups to keep, 0 keeps them all")
+	admin := flag.String("admin", "", "username of an account to make the admin, the first one also gets the history from before accounts")
+	flag.Parse()
+
+	db, err := gorm.Open(sqlite.Open(*dbPath), &gorm.Config{})
 	if err != nil {
 		panic("failed to connect database")
 	}
-	gormDB := &database.GormDB{DB: db}
-	db.AutoMigrate(&models.Deck{}, &models.Card{})
+	gormDB := &database.GormDB{DB: db, Clock: clock.Real{}}
+	if err := database.Migrate(db); err != nil {
+		log.Fatalf("Failed to migrate database: %v", err)
+	}
+	if *admin != "" {
+		user, err := database.MakeAdmin(db, *admin)
+		if errors.Is(err, database.ErrUserNotFound) {
+			log.Fatalf("No account named %s, register it before making it the admin", *admin)
+		}
+		if err != nil {
+			log.Fatalf("Failed to make %s the admin: %v", *admin, err)
+		}
+		log.Printf("%s is an admin", user.Username)
+	}
+	if !database.HasFTS5(db) {
+		log.Println("sqlite has no FTS5, card search falls back to LIKE. Build with -tags sqlite_fts5 for the full text index.")
+	}
+	go purgeTrash(gormDB)
+	backups := &database.Backups{DB: db, Dir: *backupDir, Keep: *backupKeep}
+	if *backupEvery > 0 {
+		go backUp(backups, *backupEvery)
+	}
 
 	r := gin.Default()
 
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"http://localhost:5173"},
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization"},
 		ExposeHeaders:    []string{"Content-Length"},
 		AllowCredentials: true,
 	}))
 
 	r.Static("/static", "./static")
 
-	routes.RegisterAll(r, gormDB)
+	routes.RegisterAll(r, gormDB, backups)
 
 	log.Println("Server starting on http://localhost:3030")
 	if err := r.Run(":3030"); err != nil {
@@ -57,3 +93,40 @@ func main() {
 	}
 
 }
+
+// purgeTrash deletes what has been in the trash for longer than
+// database.TrashRetention, checking every hour
+func purgeTrash(gormDB *database.GormDB) {
+	for {
+		cards, decks, err := gormDB.PurgeTrash(gormDB.Now().Add(-database.TrashRetention))
+		if err != nil {
+			log.Println("Failed to purge the trash:", err)
+		} else if cards > 0 || decks > 0 {
+			log.Printf("Purged %d cards and %d decks from the trash", cards, decks)
+		}
+		time.Sleep(time.Hour)
+	}
+}
+
+// backUp backs the database up every interval, counting from the newest
+// backup so restarting the server doesn't make one each time
+func backUp(backups *database.Backups, every time.Duration) {
+	for {
+		next := time.Now()
+		if list, err := backups.List(); err != nil {
+			log.Println("Failed to list backups:", err)
+		} else if len(list) > 0 {
+			next = list[0].CreatedAt.Add(every)
+		}
+		time.Sleep(time.Until(next))
+
+		backup, err := backups.Create(time.Now())
+		if err != nil {
+			log.Println("Failed to back up the database:", err)
+			// try again later rather than over and over
+			time.Sleep(time.Hour)
+			continue
+		}
+		log.Println("Backed up the database to", backup.Name)
+	}
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// APIToken lets scripts act as a user without logging in. Scope is one of
+// read, study, edit or admin, each allowing everything the ones before it do.
+type APIToken struct {
+	ID         uint `gorm:"primaryKey"`
+	UserID     uint `gorm:"index"`
+	Name       string
+	Scope      string
+	TokenHash  string `gorm:"uniqueIndex" json:"-"`
+	CreatedAt  time.Time
+	LastUsedAt *time.Time // nil until first used
+	ExpiresAt  *time.Time // nil never expires
+}
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// Assignment asks every student of a class to learn cards of one of the
+// class's decks by DueAt. Query narrows the deck's cards down, like
+// tag:unit-3.
+type Assignment struct {
+	ID              uint `gorm:"primaryKey"`
+	ClassID         uint `gorm:"index"`
+	DeckID          uint
+	Title           string
+	Query           string
+	TargetCards     int     // cards to learn, 0 means all of them
+	TargetRetention float64 // 0 means no retention goal
+	DueAt           time.Time
+	CreatedAt       time.Time
+}
+
+// AssignmentCompletion records when a student first met an assignment's
+// targets, so finishing late stays visible
+type AssignmentCompletion struct {
+	ID           uint `gorm:"primaryKey"`
+	AssignmentID uint `gorm:"uniqueIndex:idx_assignment_completions_assignment_user"`
+	UserID       uint `gorm:"uniqueIndex:idx_assignment_completions_assignment_user;index"`
+	CompletedAt  time.Time
+}
@@ -1,21 +1,39 @@
 package models
 
-import "time"
+import (
+	"time"
 
+	"gorm.io/gorm"
+)
+
+// Card is read through a per-user view, so the scheduling fields are the
+// studying user's own progress. They are stored in CardProgress and never
+// written to the cards table.
 type Card struct {
 	ID             uint `gorm:"primaryKey"`
 	DeckID         uint
-	Correct        uint      `gorm:"default:0"`
-	Incorrect      uint      `gorm:"default:0"`
+	HomeDeckID     *uint     `gorm:"->;-:migration"` // set while a filtered deck borrows the card
+	Correct        uint      `gorm:"->;-:migration"`
+	Incorrect      uint      `gorm:"->;-:migration"`
 	CardCreated    time.Time `gorm:"autoCreateTime"`
-	LastReviewDate time.Time
-	Stage          string `gorm:"default:'learning'"`
-	Lapses         uint   `gorm:"default:0"`
-	Ease           uint   `gorm:"default:1"`
-	ReviewDueDate  time.Time
+	LastReviewDate time.Time `gorm:"->;-:migration"`
+	Stage          string    `gorm:"->;-:migration"`
+	Lapses         uint      `gorm:"->;-:migration"`
+	Ease           uint      `gorm:"->;-:migration"`
+	ReviewDueDate  time.Time `gorm:"->;-:migration"`
+	Stability      float64   `gorm:"->;-:migration"` // FSRS memory state, 0 until first answered
+	Difficulty     float64   `gorm:"->;-:migration"`
 	Question       string
 	Answer         string
 	Extra          string
 	Audio          string
 	Image          string
+	Tags           []Tag `gorm:"many2many:card_tags"`
+	// UpdatedAt is when the content was last edited, on whichever device.
+	// SyncedAt is when the server last stored a change to it.
+	UpdatedAt time.Time
+	SyncedAt  int64  `gorm:"autoUpdateTime:nano;index" json:"-"`
+	ClientID  string `gorm:"index" json:"-"` // names cards made on an offline client
+	// DeletedAt puts the card in the trash, see database.TrashRetention
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
 }
@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// CardDeletion remembers a deleted card so syncing clients learn it is gone
+type CardDeletion struct {
+	ID        uint `gorm:"primaryKey"`
+	CardID    uint `gorm:"index"`
+	DeckID    uint `gorm:"index"`
+	DeletedAt time.Time
+	SyncedAt  int64 `gorm:"autoCreateTime:nano;index"`
+}
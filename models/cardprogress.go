@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// CardProgress is one user's scheduling state for a card. Cards a user has
+// never answered have no row and count as new.
+type CardProgress struct {
+	ID             uint  `gorm:"primaryKey"`
+	UserID         uint  `gorm:"uniqueIndex:idx_card_progress_user_card"`
+	CardID         uint  `gorm:"uniqueIndex:idx_card_progress_user_card;index"`
+	FilteredDeckID *uint `gorm:"index"` // one of the user's filtered decks borrowing the card
+	Correct        uint
+	Incorrect      uint
+	LastReviewDate time.Time
+	Stage          string
+	Lapses         uint
+	Ease           uint
+	ReviewDueDate  time.Time
+	Stability      float64
+	Difficulty     float64
+	SyncedAt       int64 `gorm:"autoUpdateTime:nano;index"`
+}
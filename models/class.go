@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// Class is a teacher's group of students. Students join with the JoinCode,
+// and decks shared with the class can be studied by all of them.
+type Class struct {
+	ID        uint `gorm:"primaryKey"`
+	Name      string
+	TeacherID uint   `gorm:"index"`
+	JoinCode  string `gorm:"uniqueIndex"`
+	CreatedAt time.Time
+}
+
+type ClassMember struct {
+	ID        uint `gorm:"primaryKey"`
+	ClassID   uint `gorm:"uniqueIndex:idx_class_members_class_user"`
+	UserID    uint `gorm:"uniqueIndex:idx_class_members_class_user;index"`
+	CreatedAt time.Time
+}
+
+// ClassDeck shares a deck the teacher owns with every student of the class
+// as viewers
+type ClassDeck struct {
+	ID      uint `gorm:"primaryKey"`
+	ClassID uint `gorm:"uniqueIndex:idx_class_decks_class_deck"`
+	DeckID  uint `gorm:"uniqueIndex:idx_class_decks_class_deck;index"`
+}
@@ -1,7 +1,18 @@
 package models
 
+import "gorm.io/gorm"
+
 type Deck struct {
-	ID    uint `gorm:"primaryKey"`
-	Name  string
-	Cards []Card `gorm:"foreignKey:DeckID;constraint:OnDelete:CASCADE"`
+	ID       uint `gorm:"primaryKey"`
+	Name     string
+	ParentID *uint  `gorm:"index"` // nil for top-level decks
+	Cards    []Card `gorm:"foreignKey:DeckID;constraint:OnDelete:CASCADE"`
+	OwnerID  uint   `gorm:"not null;default:0;index"` // user who created the deck
+	// filtered decks borrow the cards matching Filter, a search query, from
+	// their home decks
+	Filter      string
+	FilterLimit int
+	Reschedule  bool // whether answers in a filtered deck change scheduling
+	// a deck in the trash keeps its cards, members and classes for restoring
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
 }
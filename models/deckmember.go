@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// DeckMember shares a deck and its subdecks with a user as an editor, who can
+// change cards, or a viewer, who can only study them. The owner is the
+// deck's OwnerID and has no row here.
+type DeckMember struct {
+	ID        uint   `gorm:"primaryKey"`
+	DeckID    uint   `gorm:"uniqueIndex:idx_deck_members_deck_user"`
+	UserID    uint   `gorm:"uniqueIndex:idx_deck_members_deck_user;index"`
+	Role      string // editor or viewer
+	CreatedAt time.Time
+}
+
+// DeckInvite is a DeckMember waiting for the invited user to accept
+type DeckInvite struct {
+	ID        uint `gorm:"primaryKey"`
+	DeckID    uint `gorm:"index"`
+	UserID    uint `gorm:"index"`
+	InvitedBy uint
+	Role      string
+	CreatedAt time.Time
+}
@@ -0,0 +1,15 @@
+package models
+
+type DeckOptions struct {
+	ID               uint      `gorm:"primaryKey"`
+	UserID           uint      `gorm:"not null;default:0;uniqueIndex:idx_deck_options_user_deck"`
+	DeckID           uint      `gorm:"uniqueIndex:idx_deck_options_user_deck"`
+	FSRSWeights      []float64 `gorm:"serializer:json"` // empty means the default weights
+	DesiredRetention float64   `gorm:"default:0.9"`
+	// correct answers slower than this count as Hard, 0 turns it off
+	SlowAnswerSeconds float64 `gorm:"default:0"`
+	// most cards this deck contributes to one session, 0 means no limit of
+	// its own
+	LearningLimit int `gorm:"default:0"`
+	ReviewLimit   int `gorm:"default:0"`
+}
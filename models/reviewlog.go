@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+type ReviewLog struct {
+	ID               uint      `gorm:"primaryKey"`
+	UserID           uint      `gorm:"not null;default:0;index"`
+	CardID           uint      `gorm:"index"`
+	DeckID           uint      `gorm:"index"`
+	FilteredDeckID   *uint     `gorm:"index"` // the filtered deck the card was answered in, if any
+	ReviewedAt       time.Time `gorm:"index"`
+	Stage            string    // stage the card was in when it was answered
+	StageAfter       string
+	Grade            int
+	Correct          bool
+	Answer           string // what the learner typed or picked
+	DurationMs       int64  // time taken to answer, 0 if unknown
+	EaseBefore       uint
+	EaseAfter        uint
+	LastReviewBefore time.Time
+	DueBefore        time.Time
+	DueAfter         time.Time
+	StabilityBefore  float64
+	StabilityAfter   float64
+	DifficultyAfter  float64
+	Cram             bool // answered without rescheduling, left out of retention and optimisation
+	// ClientID is set by offline clients so a review sent twice is only
+	// logged once
+	ClientID string `gorm:"index"`
+	SyncedAt int64  `gorm:"autoUpdateTime:nano;index" json:"-"`
+}
@@ -0,0 +1,10 @@
+package models
+
+// One row of preferences per user. GetSettings fills in the defaults for
+// users without a row, the columns have none so zero values can be saved.
+type Settings struct {
+	ID              uint `gorm:"primaryKey"`
+	UserID          uint `gorm:"not null;default:0;uniqueIndex"`
+	Timezone        string
+	DayRolloverHour int // study days start at this local hour, like Anki's "next day starts at"
+}
@@ -0,0 +1,6 @@
+package models
+
+type Tag struct {
+	ID   uint   `gorm:"primaryKey"`
+	Name string `gorm:"uniqueIndex"` // lower case, no spaces
+}
@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+type User struct {
+	ID           uint   `gorm:"primaryKey"`
+	Username     string `gorm:"uniqueIndex"`
+	PasswordHash string `json:"-"` // bcrypt
+	CreatedAt    time.Time
+	// Admin runs the server itself, like its backups. Accounts are made
+	// admins by starting the server with -admin.
+	Admin bool `gorm:"not null;default:false"`
+}
+
+// Session is a signed in browser. Only a hash of the token is stored.
+type Session struct {
+	ID        uint   `gorm:"primaryKey"`
+	UserID    uint   `gorm:"index"`
+	TokenHash string `gorm:"uniqueIndex"`
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
@@ -0,0 +1,243 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"webproject/database"
+	"webproject/models"
+	"webproject/search"
+
+	"github.com/gin-gonic/gin"
+)
+
+// parseDueAt takes an RFC 3339 time, or a YYYY-MM-DD date meaning the end of
+// that study day
+func parseDueAt(due string, boundary database.DayBoundary) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, due); err == nil {
+		return t, nil
+	}
+	day, err := boundary.ParseDate(due)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return day.AddDate(0, 0, 1), nil
+}
+
+func RegisterAssignmentsRoutes(r *gin.Engine, gormDB *database.GormDB) {
+	assignmentParam := func(c *gin.Context, teacher bool) (*database.GormDB, models.Assignment, bool) {
+		db, classID, ok := classParam(c, gormDB, teacher)
+		if !ok {
+			return nil, models.Assignment{}, false
+		}
+		id, err := strconv.ParseUint(c.Param("assignmentID"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid assignment ID"})
+			return nil, models.Assignment{}, false
+		}
+		assignment, err := db.GetAssignment(classID, uint(id))
+		if errors.Is(err, database.ErrAssignmentNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return nil, models.Assignment{}, false
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to fetch assignment",
+				"details": err.Error(),
+			})
+			return nil, models.Assignment{}, false
+		}
+		return db, assignment, true
+	}
+
+	r.POST("/api/classes/:classID/assignments", func(c *gin.Context) {
+		db, classID, ok := classParam(c, gormDB, true)
+		if !ok {
+			return
+		}
+		var json struct {
+			Title           string  `json:"title"`
+			DeckID          uint    `json:"deck_id"`
+			Query           string  `json:"query"`
+			TargetCards     int     `json:"target_cards"`
+			TargetRetention float64 `json:"target_retention"`
+			DueAt           string  `json:"due_at"`
+		}
+		if err := c.ShouldBindJSON(&json); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "invalid JSON payload",
+				"details": err.Error(),
+			})
+			return
+		}
+		json.Title = strings.TrimSpace(json.Title)
+		if json.Title == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "assignment title cannot be empty"})
+			return
+		}
+		if json.TargetCards < 0 || json.TargetRetention < 0 || json.TargetRetention > 1 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "target_cards can't be negative and target_retention must be between 0 and 1",
+			})
+			return
+		}
+		if _, err := search.Parse(json.Query); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "invalid search query",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		boundary, err := db.GetDayBoundary()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to fetch settings",
+				"details": err.Error(),
+			})
+			return
+		}
+		dueAt, err := parseDueAt(json.DueAt, boundary)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "due_at must be an RFC 3339 time or a YYYY-MM-DD date"})
+			return
+		}
+
+		assignment, err := db.CreateAssignment(models.Assignment{
+			ClassID:         classID,
+			DeckID:          json.DeckID,
+			Title:           json.Title,
+			Query:           json.Query,
+			TargetCards:     json.TargetCards,
+			TargetRetention: json.TargetRetention,
+			DueAt:           dueAt,
+		})
+		if errors.Is(err, database.ErrDeckNotInClass) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to create assignment",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"assignment": assignment})
+	})
+
+	// the teacher sees every student's progress, students only their own
+	r.GET("/api/classes/:classID/assignments", func(c *gin.Context) {
+		db, classID, ok := classParam(c, gormDB, false)
+		if !ok {
+			return
+		}
+		boundary, err := queryDayBoundary(c, db)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid timezone"})
+			return
+		}
+		role, err := db.ClassRole(classID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to check access",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		assignments, err := db.GetClassAssignments(classID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to fetch assignments",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		results := make([]gin.H, 0, len(assignments))
+		for _, assignment := range assignments {
+			result := gin.H{"assignment": assignment}
+			if role == "teacher" {
+				result["students"], err = db.GetAssignmentProgress(assignment, boundary)
+			} else {
+				result["progress"], err = db.AssignmentProgress(assignment, boundary)
+			}
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error":   "Failed to compute assignment progress",
+					"details": err.Error(),
+				})
+				return
+			}
+			results = append(results, result)
+		}
+
+		c.JSON(http.StatusOK, gin.H{"assignments": results})
+	})
+
+	r.GET("/api/classes/:classID/assignments/:assignmentID", func(c *gin.Context) {
+		db, assignment, ok := assignmentParam(c, true)
+		if !ok {
+			return
+		}
+		boundary, err := queryDayBoundary(c, db)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid timezone"})
+			return
+		}
+
+		students, err := db.GetAssignmentProgress(assignment, boundary)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to compute assignment progress",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"assignment": assignment, "students": students})
+	})
+
+	r.DELETE("/api/classes/:classID/assignments/:assignmentID", func(c *gin.Context) {
+		db, assignment, ok := assignmentParam(c, true)
+		if !ok {
+			return
+		}
+
+		if err := db.DeleteAssignment(assignment.ClassID, assignment.ID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to delete assignment",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Assignment deleted"})
+	})
+
+	// the student's assignments across classes, ?all=1 includes completed ones
+	r.GET("/api/assignments", func(c *gin.Context) {
+		db := userDB(c, gormDB)
+		boundary, err := queryDayBoundary(c, db)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid timezone"})
+			return
+		}
+		all := c.Query("all") == "1" || c.Query("all") == "true"
+
+		assignments, err := db.GetStudentAssignments(all, boundary)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to fetch assignments",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"assignments": assignments})
+	})
+}
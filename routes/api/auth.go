@@ -0,0 +1,248 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"webproject/database"
+	"webproject/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	sessionCookie     = "session"
+	minPasswordLength = 8
+	maxUsernameLength = 64
+)
+
+// routeScopes lists the routes an API token needs more or less than the
+// default for. Reading needs read and any other change needs edit.
+var routeScopes = map[string]string{
+	// answering and filtered decks only touch the user's own progress
+	"POST /api/deck/:deckID/learning": "study",
+	"POST /api/deck/:deckID/review":   "study",
+	"POST /api/deck/:deckID/cram":     "study",
+	"POST /api/deck/:deckID/undo":     "study",
+	"POST /api/filtereddeck":          "study",
+	"POST /api/deck/:deckID/rebuild":  "study",
+	"POST /api/deck/:deckID/empty":    "study",
+	// card changes in a sync need edit on top, checked by the route
+	"POST /api/sync": "study",
+
+	"GET /api/tokens":             "admin",
+	"POST /api/tokens":            "admin",
+	"DELETE /api/tokens/:tokenID": "admin",
+
+	"GET /api/admin/backups":                "admin",
+	"POST /api/admin/backups":               "admin",
+	"POST /api/admin/backups/:name/restore": "admin",
+}
+
+// RouteScope is the scope an API token needs for the route with the method
+// and gin path
+func RouteScope(method string, path string) string {
+	if scope, ok := routeScopes[method+" "+path]; ok {
+		return scope
+	}
+	if method == http.MethodGet || method == http.MethodHead {
+		return "read"
+	}
+	return "edit"
+}
+
+// CheckRouteScopes returns an error naming the routeScopes entries that
+// aren't registered routes. A renamed route would otherwise fall back to the
+// default scope without anyone noticing.
+func CheckRouteScopes(routes gin.RoutesInfo) error {
+	registered := map[string]bool{}
+	for _, route := range routes {
+		registered[route.Method+" "+route.Path] = true
+	}
+	var stale []string
+	for route := range routeScopes {
+		if !registered[route] {
+			stale = append(stale, route)
+		}
+	}
+	if len(stale) > 0 {
+		slices.Sort(stale)
+		return fmt.Errorf("routeScopes lists routes that aren't registered: %s", strings.Join(stale, ", "))
+	}
+	return nil
+}
+
+// RequireUser rejects requests without a valid session cookie or API token,
+// and API tokens whose scope doesn't cover the route. The user is made
+// available to the handlers after it.
+func RequireUser(gormDB *database.GormDB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var user models.User
+		// a logged in browser can do anything its user can
+		scope := "admin"
+
+		if header := c.GetHeader("Authorization"); header != "" {
+			token, ok := strings.CutPrefix(header, "Bearer ")
+			if !ok || token == "" {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authorization must be a Bearer token"})
+				return
+			}
+			var err error
+			user, scope, err = gormDB.GetAPITokenUser(token)
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired API token"})
+				return
+			}
+		} else {
+			token, err := c.Cookie(sessionCookie)
+			if err != nil || token == "" {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "not logged in"})
+				return
+			}
+			user, err = gormDB.GetSessionUser(token)
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "session expired, log in again"})
+				return
+			}
+		}
+
+		if need := RouteScope(c.Request.Method, c.FullPath()); !database.ScopeAllows(scope, need) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": "this token's " + scope + " scope doesn't allow this, it needs " + need,
+			})
+			return
+		}
+		c.Set("user", user)
+		c.Set("scope", scope)
+		c.Next()
+	}
+}
+
+func currentUser(c *gin.Context) (models.User, bool) {
+	user, ok := c.Get("user")
+	if !ok {
+		return models.User{}, false
+	}
+	u, ok := user.(models.User)
+	return u, ok
+}
+
+// userDB is the database as seen by the logged in user
+func userDB(c *gin.Context, gormDB *database.GormDB) *database.GormDB {
+	if user, ok := currentUser(c); ok {
+		return gormDB.ForUser(user.ID)
+	}
+	return gormDB
+}
+
+func startSession(c *gin.Context, gormDB *database.GormDB, user models.User) bool {
+	token, session, err := gormDB.CreateSession(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to start session",
+			"details": err.Error(),
+		})
+		return false
+	}
+	maxAge := int(session.ExpiresAt.Sub(session.CreatedAt).Seconds())
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(sessionCookie, token, maxAge, "/", "", c.Request.TLS != nil, true)
+	return true
+}
+
+func RegisterAuthRoutes(r *gin.Engine, gormDB *database.GormDB) {
+	type credentials struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+
+	r.POST("/api/register", func(c *gin.Context) {
+		var json credentials
+		if err := c.ShouldBindJSON(&json); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "invalid JSON payload",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		json.Username = strings.TrimSpace(json.Username)
+		if json.Username == "" || len(json.Username) > maxUsernameLength {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "username must be between 1 and 64 characters"})
+			return
+		}
+		if len(json.Password) < minPasswordLength {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "password must be at least 8 characters"})
+			return
+		}
+
+		user, err := gormDB.CreateUser(json.Username, json.Password)
+		if errors.Is(err, database.ErrUsernameTaken) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to create user",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		if !startSession(c, gormDB, user) {
+			return
+		}
+		c.JSON(http.StatusCreated, gin.H{"user": user})
+	})
+
+	r.POST("/api/login", func(c *gin.Context) {
+		var json credentials
+		if err := c.ShouldBindJSON(&json); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "invalid JSON payload",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		user, err := gormDB.Authenticate(json.Username, json.Password)
+		if errors.Is(err, database.ErrInvalidLogin) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to log in",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		if !startSession(c, gormDB, user) {
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"user": user})
+	})
+
+	r.POST("/api/logout", func(c *gin.Context) {
+		if token, err := c.Cookie(sessionCookie); err == nil && token != "" {
+			if err := gormDB.DeleteSession(token); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error":   "Failed to log out",
+					"details": err.Error(),
+				})
+				return
+			}
+		}
+		c.SetSameSite(http.SameSiteLaxMode)
+		c.SetCookie(sessionCookie, "", -1, "/", "", c.Request.TLS != nil, true)
+		c.JSON(http.StatusOK, gin.H{"message": "logged out"})
+	})
+
+	r.GET("/api/me", RequireUser(gormDB), func(c *gin.Context) {
+		user, _ := currentUser(c)
+		c.JSON(http.StatusOK, gin.H{"user": user, "scope": c.GetString("scope")})
+	})
+}
@@ -0,0 +1,79 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"webproject/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+// requireAdmin responds with 403 unless the user runs the server
+func requireAdmin(c *gin.Context) bool {
+	if user, ok := currentUser(c); ok && user.Admin {
+		return true
+	}
+	c.JSON(http.StatusForbidden, gin.H{"error": "only the server admin can do this"})
+	return false
+}
+
+func RegisterBackupRoutes(r *gin.Engine, gormDB *database.GormDB, backups *database.Backups) {
+
+	r.GET("/api/admin/backups", func(c *gin.Context) {
+		if !requireAdmin(c) {
+			return
+		}
+		list, err := backups.List()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to list backups",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"backups": list, "keep": backups.Keep})
+	})
+
+	r.POST("/api/admin/backups", func(c *gin.Context) {
+		if !requireAdmin(c) {
+			return
+		}
+		backup, err := backups.Create(gormDB.Now())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to back up the database",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusCreated, backup)
+	})
+
+	// the database as it was before is backed up first, restoring that
+	// backup undoes the restore
+	r.POST("/api/admin/backups/:name/restore", func(c *gin.Context) {
+		if !requireAdmin(c) {
+			return
+		}
+		undo, err := backups.Restore(c.Param("name"), gormDB.Now())
+		if errors.Is(err, database.ErrBackupNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to restore backup",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":  "Backup restored",
+			"restored": c.Param("name"),
+			"undo":     undo,
+		})
+	})
+}
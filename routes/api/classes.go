@@ -0,0 +1,317 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"webproject/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+// classAccess responds and returns false unless the user is in the class,
+// as its teacher when teacher is set
+func classAccess(c *gin.Context, gormDB *database.GormDB, classID uint, teacher bool) bool {
+	role, err := gormDB.ClassRole(classID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to check access",
+			"details": err.Error(),
+		})
+		return false
+	}
+	if role == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "class not found"})
+		return false
+	}
+	if teacher && role != "teacher" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only the class's teacher can do this"})
+		return false
+	}
+	return true
+}
+
+// classParam reads :classID and checks access like classAccess
+func classParam(c *gin.Context, gormDB *database.GormDB, teacher bool) (*database.GormDB, uint, bool) {
+	db := userDB(c, gormDB)
+	classID, err := strconv.ParseUint(c.Param("classID"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid class ID"})
+		return nil, 0, false
+	}
+	if !classAccess(c, db, uint(classID), teacher) {
+		return nil, 0, false
+	}
+	return db, uint(classID), true
+}
+
+func RegisterClassesRoutes(r *gin.Engine, gormDB *database.GormDB) {
+
+	r.GET("/api/classes", func(c *gin.Context) {
+		db := userDB(c, gormDB)
+		classes, err := db.GetClasses()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to fetch classes",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"classes": classes})
+	})
+
+	r.POST("/api/classes", func(c *gin.Context) {
+		db := userDB(c, gormDB)
+		var json struct {
+			Name string `json:"name"`
+		}
+		if err := c.ShouldBindJSON(&json); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "invalid JSON payload",
+				"details": err.Error(),
+			})
+			return
+		}
+		json.Name = strings.TrimSpace(json.Name)
+		if json.Name == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "class name cannot be empty"})
+			return
+		}
+
+		class, err := db.CreateClass(json.Name)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to create class",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"class": class})
+	})
+
+	r.POST("/api/classes/join", func(c *gin.Context) {
+		db := userDB(c, gormDB)
+		var json struct {
+			Code string `json:"code"`
+		}
+		if err := c.ShouldBindJSON(&json); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "invalid JSON payload",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		class, err := db.JoinClass(json.Code)
+		if errors.Is(err, database.ErrClassNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "no class has this code"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to join class",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"class": class})
+	})
+
+	// students are only listed to the teacher
+	r.GET("/api/classes/:classID", func(c *gin.Context) {
+		db, classID, ok := classParam(c, gormDB, false)
+		if !ok {
+			return
+		}
+
+		class, err := db.GetClassByID(classID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to fetch class",
+				"details": err.Error(),
+			})
+			return
+		}
+		decks, err := db.GetClassDecks(classID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to fetch class decks",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		response := gin.H{"class": class, "decks": decks}
+		if user, _ := currentUser(c); class.TeacherID == user.ID {
+			students, err := db.GetClassStudents(classID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error":   "Failed to fetch students",
+					"details": err.Error(),
+				})
+				return
+			}
+			response["students"] = students
+		}
+		c.JSON(http.StatusOK, response)
+	})
+
+	r.DELETE("/api/classes/:classID", func(c *gin.Context) {
+		db, classID, ok := classParam(c, gormDB, true)
+		if !ok {
+			return
+		}
+
+		if err := db.DeleteClass(classID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to delete class",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Class deleted"})
+	})
+
+	// students can leave, the teacher can remove anyone
+	r.DELETE("/api/classes/:classID/students/:userID", func(c *gin.Context) {
+		userID, err := strconv.ParseUint(c.Param("userID"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+			return
+		}
+		user, _ := currentUser(c)
+		db, classID, ok := classParam(c, gormDB, user.ID != uint(userID))
+		if !ok {
+			return
+		}
+
+		err = db.RemoveClassStudent(classID, uint(userID))
+		if errors.Is(err, database.ErrStudentMissing) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to remove student",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Student removed"})
+	})
+
+	r.POST("/api/classes/:classID/decks", func(c *gin.Context) {
+		db, classID, ok := classParam(c, gormDB, true)
+		if !ok {
+			return
+		}
+		var json struct {
+			DeckID uint `json:"deck_id"`
+		}
+		if err := c.ShouldBindJSON(&json); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "invalid JSON payload",
+				"details": err.Error(),
+			})
+			return
+		}
+		if !deckAccess(c, db, json.DeckID, "owner") {
+			return
+		}
+
+		err := db.AddClassDeck(classID, json.DeckID)
+		if errors.Is(err, database.ErrNotDeckOwner) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to add deck to class",
+				"details": err.Error(),
+			})
+			return
+		}
+		deckEvent(db, "deck.changed", json.DeckID, 0).send()
+
+		c.JSON(http.StatusOK, gin.H{"message": "Deck shared with class"})
+	})
+
+	r.DELETE("/api/classes/:classID/decks/:deckID", func(c *gin.Context) {
+		db, classID, ok := classParam(c, gormDB, true)
+		if !ok {
+			return
+		}
+		deckID, err := strconv.ParseUint(c.Param("deckID"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid deck ID"})
+			return
+		}
+
+		removed := deckEvent(db, "deck.changed", uint(deckID), 0)
+		if err := db.RemoveClassDeck(classID, uint(deckID)); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to remove deck from class",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		removed.send()
+		c.JSON(http.StatusOK, gin.H{"message": "Deck removed from class"})
+	})
+
+	// the dashboard: how every student does on one of the teacher's decks
+	// shared with the class, over the last ?days=30 days
+	r.GET("/api/classes/:classID/decks/:deckID/stats", func(c *gin.Context) {
+		db, classID, ok := classParam(c, gormDB, true)
+		if !ok {
+			return
+		}
+		deckID, err := strconv.ParseUint(c.Param("deckID"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid deck ID"})
+			return
+		}
+		if !deckAccess(c, db, uint(deckID), "owner") {
+			return
+		}
+
+		boundary, err := queryDayBoundary(c, db)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid timezone"})
+			return
+		}
+		window := defaultStatsWindowDays
+		if q := c.Query("days"); q != "" {
+			if n, err := strconv.Atoi(q); err == nil && n > 0 && n <= maxStatsWindowDays {
+				window = n
+			}
+		}
+
+		stats, err := db.GetClassDeckStats(classID, uint(deckID), boundary, window)
+		if errors.Is(err, database.ErrDeckNotInClass) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to compute class stats",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"class_id": classID,
+			"deck_id":  deckID,
+			"days":     window,
+			"stats":    stats,
+		})
+	})
+}
@@ -0,0 +1,151 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"webproject/database"
+	"webproject/models"
+	"webproject/spacedrepetition"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Cram sessions serve any cards regardless of due date and only log the
+// answers, so studying ahead before a test leaves the schedule alone.
+func RegisterCramRoutes(r *gin.Engine, gormDB *database.GormDB) {
+
+	r.GET("/api/deck/:deckID/cram", func(c *gin.Context) {
+		db := userDB(c, gormDB)
+		deckIDStr, err := strconv.ParseUint(c.Param("deckID"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid deck ID"})
+			return
+		}
+		deckID := uint(deckIDStr)
+		if !deckAccess(c, db, deckID, "viewer") {
+			return
+		}
+
+		deck, err := db.GetDeckByID(deckID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "deck not found"})
+			return
+		}
+
+		limit := defaultSessionLimit
+		if q := c.Query("limit"); q != "" {
+			if n, err := strconv.Atoi(q); err == nil && n > 0 {
+				limit = n
+			}
+		}
+
+		cards, err := db.GetCramCards(deckID, limit, c.QueryArray("tag")...)
+		if err != nil || len(cards) == 0 {
+			c.JSON(http.StatusOK, gin.H{
+				"done":  true,
+				"deck":  deck,
+				"cards": []any{},
+				"msg":   "no cards to cram",
+			})
+			return
+		}
+
+		first := cards[0]
+		served.mark(db, first.ID)
+		choices, err := db.GetShuffledChoicesForCard(deckID, first)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Error while fetching multiple choice options",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"deck":    deck,
+			"cards":   cards,
+			"current": first,
+			"choices": choices,
+		})
+	})
+
+	r.POST("/api/deck/:deckID/cram", func(c *gin.Context) {
+		db := userDB(c, gormDB)
+		deckIDStr, err := strconv.ParseUint(c.Param("deckID"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid deck ID"})
+			return
+		}
+		deckID := uint(deckIDStr)
+		if !deckAccess(c, db, deckID, "viewer") {
+			return
+		}
+
+		var payload struct {
+			Answer     string        `json:"answer"`
+			Cards      []models.Card `json:"cards"`
+			DurationMs int64         `json:"duration_ms"`
+		}
+		if err := c.ShouldBindJSON(&payload); err != nil || len(payload.Cards) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload or no cards provided"})
+			return
+		}
+
+		currentCard := payload.Cards[0]
+		remainingCards := payload.Cards
+
+		isCorrect := false
+		if strings.TrimSpace(payload.Answer) != "" {
+			isCorrect = spacedrepetition.IsAnswerCorrectInLowerCase(
+				payload.Answer, currentCard.Answer)
+
+			duration := answerDuration(db, currentCard.ID, payload.DurationMs)
+			if err := db.CramAnswerCardByID(currentCard.ID, payload.Answer, isCorrect, duration); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error":   "DB update failed",
+					"details": err.Error(),
+				})
+				return
+			}
+			reviewEvent(db, "review.answered", 0, currentCard.ID).send()
+		}
+
+		// wrong answers come back at the end of the session
+		if isCorrect {
+			remainingCards = remainingCards[1:]
+		} else if len(remainingCards) > 1 {
+			remainingCards = append(remainingCards[1:], remainingCards[0])
+		}
+
+		if len(remainingCards) == 0 {
+			c.JSON(http.StatusOK, gin.H{
+				"done":    true,
+				"correct": isCorrect,
+				"cards":   []any{},
+				"choices": []any{},
+			})
+			return
+		}
+
+		nextCardToShow := remainingCards[0]
+		served.mark(db, nextCardToShow.ID)
+		choices, err := db.GetShuffledChoicesForCard(deckID, nextCardToShow)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Error while trying to get multiple choice options",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"done":       false,
+			"correct":    isCorrect,
+			"cards":      remainingCards,
+			"current":    nextCardToShow,
+			"choices":    choices,
+			"cards_left": len(remainingCards),
+		})
+	})
+}
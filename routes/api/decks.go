@@ -11,8 +11,9 @@ import (
 
 func RegisterDecksRoutes(r *gin.Engine, gormDB *database.GormDB) {
 	r.GET("/api/decks", func(c *gin.Context) {
+		db := userDB(c, gormDB)
 
-		selectedDecks, err := (*gormDB).SelectAllDecks()
+		selectedDecks, err := db.SelectAllDecks()
 
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
@@ -26,6 +27,7 @@ func RegisterDecksRoutes(r *gin.Engine, gormDB *database.GormDB) {
 	})
 
 	r.GET("/api/deck/:deckID", func(c *gin.Context) {
+		db := userDB(c, gormDB)
 
 		deckIdStr := c.Param("deckID")
 		deckId, err := strconv.ParseUint(deckIdStr, 10, 32)
@@ -36,8 +38,11 @@ func RegisterDecksRoutes(r *gin.Engine, gormDB *database.GormDB) {
 			log.Println("Invalid deck ID:", err)
 			return
 		}
+		if !deckAccess(c, db, uint(deckId), "viewer") {
+			return
+		}
 
-		selectedDeck, err := (*gormDB).GetDeckByID(uint(deckId))
+		selectedDeck, err := db.GetDeckByID(uint(deckId))
 
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
@@ -50,4 +55,77 @@ func RegisterDecksRoutes(r *gin.Engine, gormDB *database.GormDB) {
 			"deck": selectedDeck,
 		})
 	})
+
+	r.GET("/api/decks/tree", func(c *gin.Context) {
+		db := userDB(c, gormDB)
+		tree, err := db.GetDeckTree()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to fetch decks: " + err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"decks": tree,
+		})
+	})
+
+	// parent_id null moves the deck to the top level
+	r.PUT("/api/deck/:deckID/move", func(c *gin.Context) {
+		db := userDB(c, gormDB)
+		deckIdStr := c.Param("deckID")
+		deckId, err := strconv.ParseUint(deckIdStr, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid deck ID",
+			})
+			return
+		}
+		if !deckAccess(c, db, uint(deckId), "owner") {
+			return
+		}
+
+		var json struct {
+			ParentID *uint `json:"parent_id"`
+		}
+		if err := c.ShouldBindJSON(&json); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "invalid JSON payload",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		if _, err := db.GetDeckByID(uint(deckId)); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "deck not found"})
+			return
+		}
+
+		if json.ParentID != nil && !deckAccess(c, db, *json.ParentID, "editor") {
+			return
+		}
+
+		moved := deckEvent(db, "deck.changed", uint(deckId), 0)
+		if err := db.MoveDeck(uint(deckId), json.ParentID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Failed to move deck",
+				"details": err.Error(),
+			})
+			return
+		}
+		moved.and(deckEvent(db, "deck.changed", uint(deckId), 0)).send()
+
+		deck, err := db.GetDeckByID(uint(deckId))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to fetch deck: " + err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"deck": deck,
+		})
+	})
 }
@@ -0,0 +1,196 @@
+package api
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+	"webproject/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+// event tells open clients that something they show changed, they fetch
+// whatever they need again
+type event struct {
+	Type   string    `json:"type"` // card.created, card.updated, card.deleted, review.answered, review.undone or deck.changed
+	DeckID uint      `json:"deck_id"`
+	CardID uint      `json:"card_id,omitempty"`
+	UserID uint      `json:"user_id"` // who made the change
+	At     time.Time `json:"at"`
+	path   []uint    // the deck and its ancestors, for streams of one deck
+}
+
+type subscriber struct {
+	userID uint
+	deckID uint // 0 for every deck
+	events chan event
+}
+
+// eventHub fans events out to the streams of the users allowed to see them
+type eventHub struct {
+	mu          sync.Mutex
+	subscribers map[*subscriber]bool
+}
+
+var hub = &eventHub{subscribers: map[*subscriber]bool{}}
+
+const (
+	eventBuffer    = 64
+	eventHeartbeat = 25 * time.Second
+)
+
+func (h *eventHub) subscribe(userID uint, deckID uint) *subscriber {
+	s := &subscriber{userID: userID, deckID: deckID, events: make(chan event, eventBuffer)}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.subscribers[s] = true
+	return s
+}
+
+func (h *eventHub) unsubscribe(s *subscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.subscribers, s)
+}
+
+func (h *eventHub) listening() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.subscribers) > 0
+}
+
+// send drops events for streams that fall behind rather than block the
+// request that made the change
+func (h *eventHub) send(e event, users []uint) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for s := range h.subscribers {
+		if !slices.Contains(users, s.userID) || (s.deckID != 0 && !slices.Contains(e.path, s.deckID)) {
+			continue
+		}
+		select {
+		case s.events <- e:
+		default:
+		}
+	}
+}
+
+// pendingEvent is an event with its audience worked out, so changes that
+// take away access, like deleting a deck, still reach everyone who had it
+type pendingEvent struct {
+	event event
+	users []uint
+}
+
+func (p pendingEvent) send() {
+	hub.send(p.event, p.users)
+}
+
+// and also tells the other event's audience, for changes like moving a deck
+// that take it from one audience to another
+func (p pendingEvent) and(other pendingEvent) pendingEvent {
+	p.users = append(slices.Clone(p.users), other.users...)
+	p.event.path = append(slices.Clone(p.event.path), other.event.path...)
+	return p
+}
+
+// deckEvent is for changes everyone who can open the deck sees. A deckID of
+// 0 is looked up from the card, which has to happen before it is deleted.
+func deckEvent(gormDB *database.GormDB, eventType string, deckID uint, cardID uint) pendingEvent {
+	return newPendingEvent(gormDB, eventType, deckID, cardID, gormDB.DeckAudience)
+}
+
+// reviewEvent is for answers, which only the user and their teachers see
+func reviewEvent(gormDB *database.GormDB, eventType string, deckID uint, cardID uint) pendingEvent {
+	return newPendingEvent(gormDB, eventType, deckID, cardID, gormDB.ReviewAudience)
+}
+
+func newPendingEvent(gormDB *database.GormDB, eventType string, deckID uint, cardID uint,
+	audience func(deckID uint) ([]uint, []uint, error)) pendingEvent {
+	p := pendingEvent{event: event{Type: eventType, DeckID: deckID, CardID: cardID, UserID: gormDB.UserID, At: gormDB.Now()}}
+	// nobody to tell, don't bother finding out who could be told
+	if !hub.listening() {
+		return p
+	}
+	if deckID == 0 {
+		card, err := gormDB.GetCardByID(cardID)
+		if err != nil {
+			log.Println("Failed to find the card to notify about:", err)
+			return p
+		}
+		p.event.DeckID = card.DeckID
+		if card.HomeDeckID != nil {
+			p.event.DeckID = *card.HomeDeckID
+		}
+	}
+	var err error
+	if p.users, p.event.path, err = audience(p.event.DeckID); err != nil {
+		log.Println("Failed to find who to notify:", err)
+	}
+	return p
+}
+
+// userEvent is for changes only the user sees, like their deck options
+func userEvent(gormDB *database.GormDB, eventType string, deckID uint) pendingEvent {
+	p := deckEvent(gormDB, eventType, deckID, 0)
+	p.users = []uint{gormDB.UserID}
+	return p
+}
+
+// publishCardChanges tells everyone about the changes a sync stored
+func publishCardChanges(gormDB *database.GormDB, changes []database.CardChange) {
+	for _, change := range changes {
+		if change.Kind == "reviewed" {
+			reviewEvent(gormDB, "review.answered", change.DeckID, change.CardID).send()
+		} else {
+			deckEvent(gormDB, "card."+change.Kind, change.DeckID, change.CardID).send()
+		}
+	}
+}
+
+func RegisterEventsRoutes(r *gin.Engine, gormDB *database.GormDB) {
+
+	// a server-sent event stream of changes the user can see, ?deck= narrows
+	// it down to one deck and its subdecks
+	r.GET("/api/events", func(c *gin.Context) {
+		db := userDB(c, gormDB)
+		var deckID uint
+		if q := c.Query("deck"); q != "" {
+			id, err := strconv.ParseUint(q, 10, 32)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid deck ID"})
+				return
+			}
+			deckID = uint(id)
+			if !deckAccess(c, db, deckID, "viewer") {
+				return
+			}
+		}
+
+		s := hub.subscribe(db.UserID, deckID)
+		defer hub.unsubscribe(s)
+		heartbeat := time.NewTicker(eventHeartbeat)
+		defer heartbeat.Stop()
+
+		c.Header("Cache-Control", "no-cache")
+		c.Header("X-Accel-Buffering", "no")
+		c.SSEvent("ready", gin.H{"user_id": db.UserID, "deck_id": deckID})
+		c.Writer.Flush()
+		c.Stream(func(w io.Writer) bool {
+			select {
+			case <-c.Request.Context().Done():
+				return false
+			case e := <-s.events:
+				c.SSEvent(e.Type, e)
+			case <-heartbeat.C:
+				// keeps proxies from closing an idle stream
+				c.SSEvent("ping", gin.H{})
+			}
+			return true
+		})
+	})
+}
@@ -0,0 +1,135 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"webproject/database"
+	"webproject/search"
+
+	"github.com/gin-gonic/gin"
+)
+
+func isFilteredDeck(gormDB *database.GormDB, deckID uint) bool {
+	deck, err := gormDB.GetDeckByID(deckID)
+	return err == nil && deck.Filter != ""
+}
+
+func RegisterFilteredDecksRoutes(r *gin.Engine, gormDB *database.GormDB) {
+
+	// e.g. {"name": "Exam", "query": "tag:verbs lapsed:7d", "limit": 50}
+	r.POST("/api/filtereddeck", func(c *gin.Context) {
+		db := userDB(c, gormDB)
+		var json struct {
+			Name       string `json:"name"`
+			Query      string `json:"query"`
+			Limit      int    `json:"limit"`
+			Reschedule *bool  `json:"reschedule"` // defaults to true
+		}
+		if err := c.ShouldBindJSON(&json); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "invalid JSON payload",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		json.Name = strings.TrimSpace(json.Name)
+		json.Query = strings.TrimSpace(json.Query)
+		if json.Name == "" || json.Query == "" {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "name and query cannot be empty",
+			})
+			return
+		}
+		if _, err := search.Parse(json.Query); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "invalid search query",
+				"details": err.Error(),
+			})
+			return
+		}
+		if json.Limit < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit can't be negative"})
+			return
+		}
+		if json.Limit == 0 {
+			json.Limit = database.DefaultFilterLimit
+		}
+		reschedule := json.Reschedule == nil || *json.Reschedule
+
+		deck, pulled, err := db.CreateFilteredDeck(json.Name, json.Query, json.Limit, reschedule)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to create filtered deck",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		userEvent(db, "deck.changed", deck.ID).send()
+		c.JSON(http.StatusCreated, gin.H{
+			"deck":         deck,
+			"cards_pulled": pulled,
+		})
+	})
+
+	r.POST("/api/deck/:deckID/rebuild", func(c *gin.Context) {
+		db := userDB(c, gormDB)
+		deckID, err := strconv.ParseUint(c.Param("deckID"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid deck ID"})
+			return
+		}
+		if !deckAccess(c, db, uint(deckID), "owner") {
+			return
+		}
+
+		pulled, err := db.RebuildFilteredDeck(uint(deckID))
+		if errors.Is(err, database.ErrNotFilteredDeck) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to rebuild filtered deck",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		userEvent(db, "deck.changed", uint(deckID)).send()
+		c.JSON(http.StatusOK, gin.H{"cards_pulled": pulled})
+	})
+
+	// sends every card back to its home deck, the deck itself is kept so it
+	// can be rebuilt later
+	r.POST("/api/deck/:deckID/empty", func(c *gin.Context) {
+		db := userDB(c, gormDB)
+		deckID, err := strconv.ParseUint(c.Param("deckID"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid deck ID"})
+			return
+		}
+		if !deckAccess(c, db, uint(deckID), "owner") {
+			return
+		}
+
+		err = db.EmptyFilteredDeck(uint(deckID))
+		if errors.Is(err, database.ErrNotFilteredDeck) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to empty filtered deck",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		userEvent(db, "deck.changed", uint(deckID)).send()
+		c.JSON(http.StatusOK, gin.H{"message": "Filtered deck emptied"})
+	})
+}
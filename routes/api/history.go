@@ -0,0 +1,70 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"webproject/database"
+	"webproject/spacedrepetition"
+
+	"github.com/gin-gonic/gin"
+)
+
+func RegisterHistoryRoutes(r *gin.Engine, gormDB *database.GormDB) {
+
+	r.GET("/api/card/:cardID/history", func(c *gin.Context) {
+		db := userDB(c, gormDB)
+		cardIdStr := c.Param("cardID")
+		cardId, err := strconv.ParseUint(cardIdStr, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "invalid card ID",
+			})
+			return
+		}
+		if !cardAccess(c, db, uint(cardId), "viewer") {
+			return
+		}
+
+		card, err := db.GetCardByID(uint(cardId))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "card not found"})
+			return
+		}
+
+		history, err := db.GetCardHistory(card.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to fetch card history",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		options, err := db.GetDeckOptions(card.DeckID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to fetch deck options",
+				"details": err.Error(),
+			})
+			return
+		}
+		weights := spacedrepetition.ValidFSRSWeights(options.FSRSWeights)
+
+		current := gin.H{"retrievability": nil}
+		if r, ok := spacedrepetition.CurrentRetrievability(weights, card, db.Now()); ok {
+			state := spacedrepetition.CardMemoryState(weights, card)
+			current = gin.H{
+				"retrievability": r,
+				"stability":      state.Stability,
+				"difficulty":     state.Difficulty,
+				"elapsed_days":   db.Now().Sub(card.LastReviewDate).Hours() / 24,
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"card":    card,
+			"current": current,
+			"history": history,
+		})
+	})
+}
@@ -15,14 +15,18 @@ const defaultSessionLimit = 10
 func RegisterLearningRoutes(r *gin.Engine, gormDB *database.GormDB) {
 
 	r.GET("/api/deck/:deckID/learning", func(c *gin.Context) {
+		db := userDB(c, gormDB)
 		deckIDStr, err := strconv.ParseUint(c.Param("deckID"), 10, 32)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid deck ID"})
 			return
 		}
 		deckID := uint(deckIDStr)
+		if !deckAccess(c, db, deckID, "viewer") {
+			return
+		}
 
-		deck, err := gormDB.GetDeckByID(deckID)
+		deck, err := db.GetDeckByID(deckID)
 		if err != nil {
 			c.JSON(http.StatusNotFound, gin.H{"error": "deck not found"})
 			return
@@ -35,7 +39,7 @@ func RegisterLearningRoutes(r *gin.Engine, gormDB *database.GormDB) {
 			}
 		}
 
-		cards, err := gormDB.GetFirstXCards(deckID, limit, "learning")
+		cards, err := db.GetFirstXCardsInTree(deckID, limit, "learning", c.QueryArray("tag")...)
 		if err != nil || len(cards) == 0 {
 			c.JSON(http.StatusOK, gin.H{
 				"done":    true,
@@ -45,7 +49,8 @@ func RegisterLearningRoutes(r *gin.Engine, gormDB *database.GormDB) {
 		}
 
 		first := cards[0]
-		choices, err := gormDB.GetShuffledChoicesForCard(deckID, first)
+		served.mark(db, first.ID)
+		choices, err := db.GetShuffledChoicesForCard(deckID, first)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error":   "Error while fetching multiple choice options",
@@ -63,16 +68,21 @@ func RegisterLearningRoutes(r *gin.Engine, gormDB *database.GormDB) {
 		})
 	})
 	r.POST("/api/deck/:deckID/learning", func(c *gin.Context) {
+		db := userDB(c, gormDB)
 		deckIDStr, err := strconv.ParseUint(c.Param("deckID"), 10, 32)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid deck ID"})
 			return
 		}
 		deckID := uint(deckIDStr)
+		if !deckAccess(c, db, deckID, "viewer") {
+			return
+		}
 
 		var payload struct {
-			Answer string        `json:"answer"`
-			Cards  []models.Card `json:"cards"`
+			Answer     string        `json:"answer"`
+			Cards      []models.Card `json:"cards"`
+			DurationMs int64         `json:"duration_ms"`
 		}
 		if err := c.ShouldBindJSON(&payload); err != nil || len(payload.Cards) == 0 {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload or no cards provided"})
@@ -84,18 +94,23 @@ func RegisterLearningRoutes(r *gin.Engine, gormDB *database.GormDB) {
 		isCorrect := spacedrepetition.IsAnswerCorrectInLowerCase(
 			payload.Answer, currentCardFromPayload.Answer)
 
-		updatedCard, err := gormDB.UpdateLearningCardByID(currentCardFromPayload.ID, isCorrect)
+		duration := answerDuration(db, currentCardFromPayload.ID, payload.DurationMs)
+		updatedCard, err := db.UpdateLearningCardByID(currentCardFromPayload.ID, payload.Answer, isCorrect, duration)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError,
 				gin.H{"error": "DB update failed", "details": err.Error()})
 			return
 		}
+		reviewEvent(db, "review.answered", 0, updatedCard.ID).send()
 
 		remainingCards := payload.Cards
-		cardGraduatedToReview := (updatedCard.Stage == "review")
+		// a filtered deck that doesn't reschedule sends the card home instead
+		// of graduating it
+		cardDone := updatedCard.Stage == "review" ||
+			(currentCardFromPayload.HomeDeckID != nil && updatedCard.HomeDeckID == nil)
 
 		if isCorrect {
-			if cardGraduatedToReview {
+			if cardDone {
 				remainingCards = remainingCards[1:]
 			} else {
 				if len(remainingCards) > 1 {
@@ -121,7 +136,8 @@ func RegisterLearningRoutes(r *gin.Engine, gormDB *database.GormDB) {
 		}
 
 		nextCardToShow := remainingCards[0]
-		choices, err := gormDB.GetShuffledChoicesForCard(deckID, nextCardToShow)
+		served.mark(db, nextCardToShow.ID)
+		choices, err := db.GetShuffledChoicesForCard(deckID, nextCardToShow)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error":   "Error while trying to get multiple choice options",
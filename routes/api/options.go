@@ -0,0 +1,267 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"webproject/database"
+	"webproject/simulation"
+	"webproject/spacedrepetition"
+
+	"github.com/gin-gonic/gin"
+)
+
+// each projection is a whole simulation run inside the request. Besides each
+// parameter, the cards times days summed over the projections is capped, so
+// no request keeps the server busy for long.
+const (
+	maxProjectionDays     = 365
+	maxProjectionNew      = 100
+	maxProjectionValues   = 10
+	maxProjectionCardDays = 1_000_000
+)
+
+func RegisterOptionsRoutes(r *gin.Engine, gormDB *database.GormDB) {
+
+	r.GET("/api/deck/:deckID/options", func(c *gin.Context) {
+		db := userDB(c, gormDB)
+		deckIDStr, err := strconv.ParseUint(c.Param("deckID"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid deck ID"})
+			return
+		}
+		deckID := uint(deckIDStr)
+		if !deckAccess(c, db, deckID, "viewer") {
+			return
+		}
+
+		if _, err := db.GetDeckByID(deckID); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "deck not found"})
+			return
+		}
+
+		options, err := db.GetDeckOptions(deckID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to fetch deck options",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"options":      options,
+			"fsrs_weights": spacedrepetition.ValidFSRSWeights(options.FSRSWeights),
+		})
+	})
+
+	r.PUT("/api/deck/:deckID/options", func(c *gin.Context) {
+		db := userDB(c, gormDB)
+		deckIDStr, err := strconv.ParseUint(c.Param("deckID"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid deck ID"})
+			return
+		}
+		deckID := uint(deckIDStr)
+		if !deckAccess(c, db, deckID, "viewer") {
+			return
+		}
+
+		if _, err := db.GetDeckByID(deckID); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "deck not found"})
+			return
+		}
+
+		// fields left out keep their current value
+		var json struct {
+			DesiredRetention  *float64 `json:"desired_retention"`
+			SlowAnswerSeconds *float64 `json:"slow_answer_seconds"`
+			LearningLimit     *int     `json:"learning_limit"`
+			ReviewLimit       *int     `json:"review_limit"`
+		}
+		if err := c.ShouldBindJSON(&json); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "invalid JSON payload",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		if json.DesiredRetention != nil && (*json.DesiredRetention < spacedrepetition.MinDesiredRetention ||
+			*json.DesiredRetention > spacedrepetition.MaxDesiredRetention) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "desired_retention must be between 0.7 and 0.99",
+			})
+			return
+		}
+		if json.SlowAnswerSeconds != nil && *json.SlowAnswerSeconds < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "slow_answer_seconds can't be negative",
+			})
+			return
+		}
+		if (json.LearningLimit != nil && *json.LearningLimit < 0) ||
+			(json.ReviewLimit != nil && *json.ReviewLimit < 0) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "limits can't be negative",
+			})
+			return
+		}
+
+		options, err := db.GetDeckOptions(deckID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to fetch deck options",
+				"details": err.Error(),
+			})
+			return
+		}
+		options.DeckID = deckID
+		if json.DesiredRetention != nil {
+			options.DesiredRetention = *json.DesiredRetention
+		}
+		if json.SlowAnswerSeconds != nil {
+			options.SlowAnswerSeconds = *json.SlowAnswerSeconds
+		}
+		if json.LearningLimit != nil {
+			options.LearningLimit = *json.LearningLimit
+		}
+		if json.ReviewLimit != nil {
+			options.ReviewLimit = *json.ReviewLimit
+		}
+
+		if err := db.SaveDeckOptions(options); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to save deck options",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		userEvent(db, "deck.changed", deckID).send()
+		c.JSON(http.StatusOK, gin.H{"options": options})
+	})
+
+	// Simulates the deck from scratch for each requested retention so learners
+	// can see what a setting costs before choosing it.
+	r.GET("/api/deck/:deckID/workload", func(c *gin.Context) {
+		db := userDB(c, gormDB)
+		deckIDStr, err := strconv.ParseUint(c.Param("deckID"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid deck ID"})
+			return
+		}
+		deckID := uint(deckIDStr)
+		if !deckAccess(c, db, deckID, "viewer") {
+			return
+		}
+
+		if _, err := db.GetDeckByID(deckID); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "deck not found"})
+			return
+		}
+
+		options, err := db.GetDeckOptions(deckID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to fetch deck options",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		cfg := simulation.DefaultConfig()
+		cfg.Start = db.Now()
+		if q := c.Query("days"); q != "" {
+			if n, err := strconv.Atoi(q); err == nil && n > 0 && n <= maxProjectionDays {
+				cfg.Days = n
+			}
+		}
+		if q := c.Query("new"); q != "" {
+			n, err := strconv.Atoi(q)
+			if err != nil || n > maxProjectionNew {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error": fmt.Sprintf("new must be a number up to %d", maxProjectionNew),
+				})
+				return
+			}
+			if n > 0 {
+				cfg.NewCardsPerDay = n
+			}
+		}
+
+		retentions := []float64{options.DesiredRetention}
+		if values := c.QueryArray("retention"); len(values) > 0 {
+			if len(values) > maxProjectionValues {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error": fmt.Sprintf("at most %d retention values can be compared", maxProjectionValues),
+				})
+				return
+			}
+			retentions = retentions[:0]
+			for _, v := range values {
+				retention, err := strconv.ParseFloat(v, 64)
+				if err != nil || retention < spacedrepetition.MinDesiredRetention ||
+					retention > spacedrepetition.MaxDesiredRetention {
+					c.JSON(http.StatusBadRequest, gin.H{
+						"error": "retention must be between 0.7 and 0.99",
+					})
+					return
+				}
+				retentions = append(retentions, retention)
+			}
+		}
+
+		cards, err := db.GetAllCardsByDeckID(deckID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to fetch cards",
+				"details": err.Error(),
+			})
+			return
+		}
+		if len(cards)*cfg.Days*len(retentions) > maxProjectionCardDays {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": fmt.Sprintf("the deck's %d cards over %d days for %d retention values is too much to project, ask for fewer days or values",
+					len(cards), cfg.Days, len(retentions)),
+			})
+			return
+		}
+
+		var projections []gin.H
+		for _, retention := range retentions {
+			options.DesiredRetention = retention
+			sandbox, fake, sandboxDeckID, err := simulation.NewSandbox(cards, options, cfg.Start)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error":   "Failed to set up simulation",
+					"details": err.Error(),
+				})
+				return
+			}
+
+			report, err := simulation.Run(sandbox, fake, sandboxDeckID, cfg)
+			if closeErr := simulation.Close(sandbox); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error":   "Simulation failed",
+					"details": err.Error(),
+				})
+				return
+			}
+
+			projections = append(projections, gin.H{
+				"desired_retention": retention,
+				"report":            report,
+			})
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"days":        cfg.Days,
+			"new_per_day": cfg.NewCardsPerDay,
+			"projections": projections,
+		})
+	})
+}
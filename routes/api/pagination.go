@@ -0,0 +1,26 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// pageQuery reads ?page= and ?per_page=, responding with 400 and returning
+// false when they are out of range
+func pageQuery(c *gin.Context, defaultSize int, maxSize int) (page int, perPage int, ok bool) {
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "page must be a positive number"})
+		return 0, 0, false
+	}
+	perPage, err = strconv.Atoi(c.DefaultQuery("per_page", strconv.Itoa(defaultSize)))
+	if err != nil || perPage < 1 || perPage > maxSize {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "per_page must be between 1 and " + strconv.Itoa(maxSize),
+		})
+		return 0, 0, false
+	}
+	return page, perPage, true
+}
@@ -14,14 +14,18 @@ import (
 func RegisterReviewRoutes(r *gin.Engine, gormDB *database.GormDB) {
 
 	r.GET("/api/deck/:deckID/review", func(c *gin.Context) {
+		db := userDB(c, gormDB)
 		deckIDStr, err := strconv.ParseUint(c.Param("deckID"), 10, 32)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid deck ID"})
 			return
 		}
 		deckID := uint(deckIDStr)
+		if !deckAccess(c, db, deckID, "viewer") {
+			return
+		}
 
-		deck, err := gormDB.GetDeckByID(deckID)
+		deck, err := db.GetDeckByID(deckID)
 		if err != nil {
 			c.JSON(http.StatusNotFound, gin.H{"error": "deck not found"})
 			return
@@ -34,7 +38,7 @@ func RegisterReviewRoutes(r *gin.Engine, gormDB *database.GormDB) {
 			}
 		}
 
-		cards, err := gormDB.GetFirstXCards(deckID, limit, "review")
+		cards, err := db.GetFirstXCardsInTree(deckID, limit, "review", c.QueryArray("tag")...)
 		if err != nil || len(cards) == 0 {
 			c.JSON(http.StatusOK, gin.H{
 				"done":  true,
@@ -46,7 +50,8 @@ func RegisterReviewRoutes(r *gin.Engine, gormDB *database.GormDB) {
 		}
 
 		first := cards[0]
-		choices, err := gormDB.GetShuffledChoicesForCard(deckID, first)
+		served.mark(db, first.ID)
+		choices, err := db.GetShuffledChoicesForCard(deckID, first)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error":   "Error while fetching multiple choice options",
@@ -65,16 +70,21 @@ func RegisterReviewRoutes(r *gin.Engine, gormDB *database.GormDB) {
 	})
 
 	r.POST("/api/deck/:deckID/review", func(c *gin.Context) {
+		db := userDB(c, gormDB)
 		deckIDStr, err := strconv.ParseUint(c.Param("deckID"), 10, 32)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid deck ID"})
 			return
 		}
 		deckID := uint(deckIDStr)
+		if !deckAccess(c, db, deckID, "viewer") {
+			return
+		}
 
 		var payload struct {
-			Answer string        `json:"answer"`
-			Cards  []models.Card `json:"cards"`
+			Answer     string        `json:"answer"`
+			Cards      []models.Card `json:"cards"`
+			DurationMs int64         `json:"duration_ms"`
 		}
 		if err := c.ShouldBindJSON(&payload); err != nil || len(payload.Cards) == 0 {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload or no cards provided"})
@@ -91,13 +101,15 @@ func RegisterReviewRoutes(r *gin.Engine, gormDB *database.GormDB) {
 			isCorrect = spacedrepetition.IsAnswerCorrectInLowerCase(
 				payload.Answer, currentCard.Answer)
 
-			if err := gormDB.UpdateReviewCardByID(currentCard.ID, isCorrect); err != nil {
+			duration := answerDuration(db, currentCard.ID, payload.DurationMs)
+			if err := db.UpdateReviewCardByID(currentCard.ID, payload.Answer, isCorrect, duration); err != nil {
 				c.JSON(http.StatusInternalServerError, gin.H{
 					"error":   "DB update failed",
 					"details": err.Error(),
 				})
 				return
 			}
+			reviewEvent(db, "review.answered", 0, currentCard.ID).send()
 		}
 		if isCorrect {
 			if len(remainingCards) > 0 {
@@ -121,7 +133,8 @@ func RegisterReviewRoutes(r *gin.Engine, gormDB *database.GormDB) {
 		}
 
 		nextCardToShow := remainingCards[0]
-		choices, err := gormDB.GetShuffledChoicesForCard(deckID, nextCardToShow)
+		served.mark(db, nextCardToShow.ID)
+		choices, err := db.GetShuffledChoicesForCard(deckID, nextCardToShow)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error":   "Error while trying to get multiple choice options",
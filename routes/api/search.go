@@ -0,0 +1,51 @@
+package api
+
+import (
+	"net/http"
+	"webproject/database"
+	"webproject/search"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultSearchPageSize = 50
+	maxSearchPageSize     = 500
+)
+
+func RegisterSearchRoutes(r *gin.Engine, gormDB *database.GormDB) {
+
+	// e.g. /api/cards/search?q=tag:verbs (lapses>=3 OR ease<2) -deck:Archive
+	r.GET("/api/cards/search", func(c *gin.Context) {
+		db := userDB(c, gormDB)
+		query, err := search.Parse(c.Query("q"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "invalid search query",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		page, perPage, ok := pageQuery(c, defaultSearchPageSize, maxSearchPageSize)
+		if !ok {
+			return
+		}
+
+		cards, total, err := db.SearchCards(query, (page-1)*perPage, perPage)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to search cards",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"cards":    cards,
+			"total":    total,
+			"page":     page,
+			"per_page": perPage,
+		})
+	})
+}
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"time"
+	"webproject/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+func RegisterSettingsRoutes(r *gin.Engine, gormDB *database.GormDB) {
+
+	r.GET("/api/settings", func(c *gin.Context) {
+		db := userDB(c, gormDB)
+		settings, err := db.GetSettings()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to fetch settings",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"settings": settings})
+	})
+
+	r.PUT("/api/settings", func(c *gin.Context) {
+		db := userDB(c, gormDB)
+		var json struct {
+			Timezone        string `json:"timezone"`
+			DayRolloverHour int    `json:"day_rollover_hour"`
+		}
+		if err := c.ShouldBindJSON(&json); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "invalid JSON payload",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		json.Timezone = strings.TrimSpace(json.Timezone)
+		if json.Timezone == "" {
+			json.Timezone = "UTC"
+		}
+		if _, err := time.LoadLocation(json.Timezone); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "unknown timezone"})
+			return
+		}
+		if json.DayRolloverHour < 0 || json.DayRolloverHour > 23 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "day_rollover_hour must be between 0 and 23",
+			})
+			return
+		}
+
+		settings, err := db.GetSettings()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to fetch settings",
+				"details": err.Error(),
+			})
+			return
+		}
+		settings.Timezone = json.Timezone
+		settings.DayRolloverHour = json.DayRolloverHour
+
+		if err := db.SaveSettings(settings); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to save settings",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"settings": settings})
+	})
+}
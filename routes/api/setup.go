@@ -1,22 +1,30 @@
 package api
 
 import (
+	"errors"
 	"log"
 	"net/http"
 	"strconv"
 	"strings"
-	"time"
 	"webproject/database"
 	"webproject/models"
 
 	"github.com/gin-gonic/gin"
 )
 
+const (
+	defaultCardPageSize = 100
+	maxCardPageSize     = 1000
+)
+
 func RegisterSetupRoutes(r *gin.Engine, gormDB *database.GormDB) {
 
 	r.POST("/api/createdeck", func(c *gin.Context) {
+		db := userDB(c, gormDB)
+		// names like "Spanish::Verbs" create the missing parent decks too
 		var json struct {
-			Name string `json:"name"`
+			Name     string `json:"name"`
+			ParentID *uint  `json:"parent_id"`
 		}
 
 		if err := c.BindJSON(&json); err != nil {
@@ -30,27 +38,54 @@ func RegisterSetupRoutes(r *gin.Engine, gormDB *database.GormDB) {
 			c.JSON(http.StatusBadRequest, gin.H{
 				"error": "deck name cannot be empty",
 			})
+			return
+		}
+
+		if json.ParentID != nil {
+			if !deckAccess(c, db, *json.ParentID, "editor") {
+				return
+			}
+			parent, err := db.GetDeckByID(*json.ParentID)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error": "parent deck not found",
+				})
+				return
+			}
+			if parent.Filter != "" {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error": "filtered decks can't have subdecks",
+				})
+				return
+			}
 		}
 
-		deck := models.Deck{Name: deckName}
-		if err := gormDB.DB.Create(&deck).Error; err != nil {
+		deck, err := db.CreateDeckPath(deckName, json.ParentID)
+		if errors.Is(err, database.ErrDeckExists) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error": "Failed to create deck: " + err.Error(),
 			})
 			return
 		}
+		deckEvent(db, "deck.changed", deck.ID, 0).send()
 
 		c.JSON(http.StatusCreated, gin.H{
 			"message": "Deck created successfully",
 			"deck": gin.H{
-				"id":   deck.ID,
-				"name": deck.Name,
+				"id":        deck.ID,
+				"name":      deck.Name,
+				"parent_id": deck.ParentID,
 			},
 		})
 
 	})
 
 	r.POST("/api/deck/:deckID/createcard", func(c *gin.Context) {
+		db := userDB(c, gormDB)
 
 		deckIdStr := c.Param("deckID")
 		deckId, err := strconv.ParseUint(deckIdStr, 10, 32)
@@ -61,11 +96,21 @@ func RegisterSetupRoutes(r *gin.Engine, gormDB *database.GormDB) {
 			log.Println("Invalid deck ID:", err)
 			return
 		}
+		if !deckAccess(c, db, uint(deckId), "editor") {
+			return
+		}
+		if isFilteredDeck(db, uint(deckId)) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "cards can't be added to a filtered deck",
+			})
+			return
+		}
 
 		var json struct {
-			Question string `json:"question"`
-			Answer   string `json:"answer"`
-			Extra    string `json:"extra"`
+			Question string   `json:"question"`
+			Answer   string   `json:"answer"`
+			Extra    string   `json:"extra"`
+			Tags     []string `json:"tags"`
 		}
 
 		if err := c.ShouldBindJSON(&json); err != nil {
@@ -92,23 +137,26 @@ func RegisterSetupRoutes(r *gin.Engine, gormDB *database.GormDB) {
 			Question:      json.Question,
 			Answer:        json.Answer,
 			Extra:         json.Extra,
-			CardCreated:   time.Now().UTC(),
-			ReviewDueDate: time.Now().UTC(),
+			CardCreated:   db.Now(),
+			ReviewDueDate: db.Now(),
 		}
 
-		if err := gormDB.DB.Create(&card).Error; err != nil {
+		card, err = db.CreateCardWithTags(card, json.Tags)
+		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error":   "Failed to create card",
 				"details": err.Error(),
 			})
 			return
 		}
+		deckEvent(db, "card.created", card.DeckID, card.ID).send()
 
 		c.JSON(http.StatusCreated, card)
 
 	})
 
 	r.PUT("/api/card/:cardID/edit", func(c *gin.Context) {
+		db := userDB(c, gormDB)
 		cardIdStr := c.Param("cardID")
 		cardId, err := strconv.ParseUint(cardIdStr, 10, 32)
 		if err != nil {
@@ -117,6 +165,9 @@ func RegisterSetupRoutes(r *gin.Engine, gormDB *database.GormDB) {
 			})
 			return
 		}
+		if !cardAccess(c, db, uint(cardId), "editor") {
+			return
+		}
 
 		var json struct {
 			Question string `json:"question"`
@@ -144,7 +195,7 @@ func RegisterSetupRoutes(r *gin.Engine, gormDB *database.GormDB) {
 
 		}
 
-		err = gormDB.UpdateCardByID(uint(cardId), json.Question, json.Answer, json.Extra)
+		err = db.UpdateCardByID(uint(cardId), json.Question, json.Answer, json.Extra)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error":   "failed to update card",
@@ -153,7 +204,7 @@ func RegisterSetupRoutes(r *gin.Engine, gormDB *database.GormDB) {
 			return
 		}
 
-		card, err := gormDB.GetCardByID(uint(cardId))
+		card, err := db.GetCardByID(uint(cardId))
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error":   "failed to get card after update",
@@ -161,12 +212,14 @@ func RegisterSetupRoutes(r *gin.Engine, gormDB *database.GormDB) {
 			})
 			return
 		}
+		deckEvent(db, "card.updated", 0, card.ID).send()
 
 		c.JSON(http.StatusOK, card)
 
 	})
 
 	r.DELETE("/api/deck/:deckID", func(c *gin.Context) {
+		db := userDB(c, gormDB)
 		deckIdStr := c.Param("deckID")
 		deckId, err := strconv.ParseInt(deckIdStr, 10, 32)
 		if err != nil {
@@ -175,8 +228,12 @@ func RegisterSetupRoutes(r *gin.Engine, gormDB *database.GormDB) {
 			})
 			return
 		}
+		if !deckAccess(c, db, uint(deckId), "owner") {
+			return
+		}
 
-		err = gormDB.DeleteDeckByID(uint(deckId))
+		deleted := deckEvent(db, "deck.changed", uint(deckId), 0)
+		err = db.DeleteDeckByID(uint(deckId))
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error":   "Failed to delete deck",
@@ -184,6 +241,7 @@ func RegisterSetupRoutes(r *gin.Engine, gormDB *database.GormDB) {
 			})
 			return
 		}
+		deleted.send()
 
 		c.JSON(http.StatusOK, gin.H{
 			"message": "Deck deleted successfully",
@@ -192,6 +250,7 @@ func RegisterSetupRoutes(r *gin.Engine, gormDB *database.GormDB) {
 	})
 
 	r.GET("/api/deck/:deckID/cards", func(c *gin.Context) {
+		db := userDB(c, gormDB)
 		deckIdStr := c.Param("deckID")
 		deckId, err := strconv.ParseInt(deckIdStr, 10, 32)
 		if err != nil {
@@ -200,8 +259,11 @@ func RegisterSetupRoutes(r *gin.Engine, gormDB *database.GormDB) {
 			})
 			return
 		}
+		if !deckAccess(c, db, uint(deckId), "viewer") {
+			return
+		}
 
-		deck, err := (*gormDB).GetDeckByID(uint(deckId))
+		deck, err := db.GetDeckByID(uint(deckId))
 
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
@@ -210,23 +272,70 @@ func RegisterSetupRoutes(r *gin.Engine, gormDB *database.GormDB) {
 			return
 		}
 
-		cards, err := gormDB.GetAllCardsByDeckID(deck.ID)
+		// e.g. ?sort=due&order=desc&page=2&fields=question,answer,tags
+		page, perPage, ok := pageQuery(c, defaultCardPageSize, maxCardPageSize)
+		if !ok {
+			return
+		}
+		opts := database.CardListOptions{
+			Tags:   c.QueryArray("tag"),
+			Sort:   c.Query("sort"),
+			Desc:   c.Query("order") == "desc",
+			Offset: (page - 1) * perPage,
+			Limit:  perPage,
+		}
+		if _, known := database.CardSortColumns[opts.Sort]; opts.Sort != "" && !known {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "sort must be one of created, due, ease, lapses or question",
+			})
+			return
+		}
+		if order := c.Query("order"); order != "" && order != "asc" && order != "desc" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "order must be asc or desc"})
+			return
+		}
+
+		var fields []string
+		if f := c.Query("fields"); f != "" {
+			for _, field := range strings.Split(f, ",") {
+				field = strings.TrimSpace(field)
+				if !database.CardFields[field] {
+					c.JSON(http.StatusBadRequest, gin.H{
+						"error": "unknown field " + field,
+					})
+					return
+				}
+				fields = append(fields, field)
+			}
+		}
+
+		var cards any
+		var total int64
+		if len(fields) > 0 {
+			cards, total, err = db.ListCardFields(deck.ID, opts, fields)
+		} else {
+			cards, total, err = db.ListCards(deck.ID, opts)
+		}
 		if err != nil {
-			c.JSON(http.StatusOK, gin.H{
-				"error":   "No cards in this deck",
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to fetch cards",
 				"details": err.Error(),
-				"cards":   []any{},
 			})
 			return
 		}
 
+		c.Header("X-Total-Count", strconv.FormatInt(total, 10))
 		c.JSON(http.StatusOK, gin.H{
-			"cards": cards,
+			"cards":    cards,
+			"total":    total,
+			"page":     page,
+			"per_page": perPage,
 		})
 
 	})
 
 	r.DELETE("/api/card/:cardID/delete", func(c *gin.Context) {
+		db := userDB(c, gormDB)
 		cardIdStr := c.Param("cardID")
 		cardId, err := strconv.ParseUint(cardIdStr, 10, 32)
 		if err != nil {
@@ -235,8 +344,12 @@ func RegisterSetupRoutes(r *gin.Engine, gormDB *database.GormDB) {
 			})
 			return
 		}
+		if !cardAccess(c, db, uint(cardId), "editor") {
+			return
+		}
 
-		err = gormDB.DeleteCardByID(uint(cardId))
+		deleted := deckEvent(db, "card.deleted", 0, uint(cardId))
+		err = db.DeleteCardByID(uint(cardId))
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error":   "Failed to delete card",
@@ -244,6 +357,7 @@ func RegisterSetupRoutes(r *gin.Engine, gormDB *database.GormDB) {
 			})
 			return
 		}
+		deleted.send()
 
 		c.JSON(http.StatusOK, gin.H{
 			"message": "Card deleted successfully",
@@ -253,12 +367,20 @@ func RegisterSetupRoutes(r *gin.Engine, gormDB *database.GormDB) {
 	})
 
 	r.POST("/api/deck/:deckID/batchadd", func(c *gin.Context) {
+		db := userDB(c, gormDB)
 		deckIdStr := c.Param("deckID")
 		deckId, err := strconv.ParseUint(deckIdStr, 10, 32)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid deck ID"})
 			return
 		}
+		if !deckAccess(c, db, uint(deckId), "editor") {
+			return
+		}
+		if isFilteredDeck(db, uint(deckId)) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "cards can't be added to a filtered deck"})
+			return
+		}
 
 		var json struct {
 			Lines []string `json:"lines"`
@@ -268,20 +390,26 @@ func RegisterSetupRoutes(r *gin.Engine, gormDB *database.GormDB) {
 			return
 		}
 
+		// lines are "question;answer" with an optional third column of
+		// space separated tags
 		var numberOfCards int
 		for _, line := range json.Lines {
 			parts := strings.Split(line, ";")
-			if len(parts) != 2 {
+			if len(parts) != 2 && len(parts) != 3 {
 				continue
 			}
 			card := models.Card{
 				DeckID:        uint(deckId),
 				Question:      strings.TrimSpace(parts[0]),
 				Answer:        strings.TrimSpace(parts[1]),
-				CardCreated:   time.Now().UTC(),
-				ReviewDueDate: time.Now().UTC(),
+				CardCreated:   db.Now(),
+				ReviewDueDate: db.Now(),
 			}
-			err := gormDB.CreateCard(card)
+			var tags []string
+			if len(parts) == 3 {
+				tags = []string{parts[2]}
+			}
+			_, err := db.CreateCardWithTags(card, tags)
 
 			if err == nil {
 				numberOfCards++
@@ -294,6 +422,10 @@ func RegisterSetupRoutes(r *gin.Engine, gormDB *database.GormDB) {
 			}
 		}
 
+		if numberOfCards > 0 {
+			deckEvent(db, "card.created", uint(deckId), 0).send()
+		}
+
 		c.JSON(http.StatusOK, gin.H{
 			"message":           "Batch add completed",
 			"cards_added_count": numberOfCards,
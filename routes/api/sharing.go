@@ -0,0 +1,259 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"webproject/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+// deckAccess responds and returns false unless the user has at least role on
+// the deck. Decks they can't open at all are not found, so their existence
+// doesn't leak.
+func deckAccess(c *gin.Context, gormDB *database.GormDB, deckID uint, role string) bool {
+	have, err := gormDB.DeckRole(deckID)
+	return checkRole(c, have, role, err, "deck not found")
+}
+
+// cardAccess is deckAccess for the card's home deck
+func cardAccess(c *gin.Context, gormDB *database.GormDB, cardID uint, role string) bool {
+	have, err := gormDB.CardRole(cardID)
+	return checkRole(c, have, role, err, "card not found")
+}
+
+func checkRole(c *gin.Context, have string, need string, err error, notFound string) bool {
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to check access",
+			"details": err.Error(),
+		})
+		return false
+	}
+	if have == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": notFound})
+		return false
+	}
+	if !database.RoleAllows(have, need) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "this needs the " + need + " role, you are a " + have})
+		return false
+	}
+	return true
+}
+
+func RegisterSharingRoutes(r *gin.Engine, gormDB *database.GormDB) {
+	deckParam := func(c *gin.Context, role string) (*database.GormDB, uint, bool) {
+		db := userDB(c, gormDB)
+		deckID, err := strconv.ParseUint(c.Param("deckID"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid deck ID"})
+			return nil, 0, false
+		}
+		if !deckAccess(c, db, uint(deckID), role) {
+			return nil, 0, false
+		}
+		return db, uint(deckID), true
+	}
+
+	respondWithError := func(c *gin.Context, err error, message string) {
+		switch {
+		case errors.Is(err, database.ErrUserNotFound), errors.Is(err, database.ErrInviteNotFound),
+			errors.Is(err, database.ErrNotMember):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, database.ErrUnknownRole):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, database.ErrAlreadyMember):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   message,
+				"details": err.Error(),
+			})
+		}
+	}
+
+	r.GET("/api/deck/:deckID/members", func(c *gin.Context) {
+		db, deckID, ok := deckParam(c, "viewer")
+		if !ok {
+			return
+		}
+
+		members, err := db.GetDeckMembers(deckID)
+		if err != nil {
+			respondWithError(c, err, "Failed to fetch members")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"members": members})
+	})
+
+	// a member can leave a deck, everything else about members is up to the
+	// owner
+	r.PUT("/api/deck/:deckID/members/:userID", func(c *gin.Context) {
+		db, deckID, ok := deckParam(c, "owner")
+		if !ok {
+			return
+		}
+		userID, err := strconv.ParseUint(c.Param("userID"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+			return
+		}
+
+		var json struct {
+			Role string `json:"role"`
+		}
+		if err := c.ShouldBindJSON(&json); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "invalid JSON payload",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		if err := db.SetDeckMemberRole(deckID, uint(userID), json.Role); err != nil {
+			respondWithError(c, err, "Failed to change role")
+			return
+		}
+		deckEvent(db, "deck.changed", deckID, 0).send()
+
+		c.JSON(http.StatusOK, gin.H{"message": "Role changed"})
+	})
+
+	r.DELETE("/api/deck/:deckID/members/:userID", func(c *gin.Context) {
+		userID, err := strconv.ParseUint(c.Param("userID"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+			return
+		}
+		role := "owner"
+		if user, _ := currentUser(c); user.ID == uint(userID) {
+			role = "viewer"
+		}
+		db, deckID, ok := deckParam(c, role)
+		if !ok {
+			return
+		}
+
+		removed := deckEvent(db, "deck.changed", deckID, 0)
+		if err := db.RemoveDeckMember(deckID, uint(userID)); err != nil {
+			respondWithError(c, err, "Failed to remove member")
+			return
+		}
+		removed.send()
+
+		c.JSON(http.StatusOK, gin.H{"message": "Member removed"})
+	})
+
+	r.GET("/api/deck/:deckID/invites", func(c *gin.Context) {
+		db, deckID, ok := deckParam(c, "owner")
+		if !ok {
+			return
+		}
+
+		invites, err := db.GetDeckInvites(deckID)
+		if err != nil {
+			respondWithError(c, err, "Failed to fetch invites")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"invites": invites})
+	})
+
+	r.POST("/api/deck/:deckID/invites", func(c *gin.Context) {
+		db, deckID, ok := deckParam(c, "owner")
+		if !ok {
+			return
+		}
+		if isFilteredDeck(db, deckID) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "filtered decks can't be shared"})
+			return
+		}
+
+		var json struct {
+			Username string `json:"username"`
+			Role     string `json:"role"`
+		}
+		if err := c.ShouldBindJSON(&json); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "invalid JSON payload",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		invite, err := db.InviteToDeck(deckID, strings.TrimSpace(json.Username), json.Role)
+		if err != nil {
+			respondWithError(c, err, "Failed to invite user")
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"invite": invite})
+	})
+
+	r.DELETE("/api/deck/:deckID/invites/:inviteID", func(c *gin.Context) {
+		db, deckID, ok := deckParam(c, "owner")
+		if !ok {
+			return
+		}
+		inviteID, err := strconv.ParseUint(c.Param("inviteID"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invite ID"})
+			return
+		}
+
+		if err := db.CancelDeckInvite(deckID, uint(inviteID)); err != nil {
+			respondWithError(c, err, "Failed to cancel invite")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Invite cancelled"})
+	})
+
+	r.GET("/api/invites", func(c *gin.Context) {
+		db := userDB(c, gormDB)
+		invites, err := db.GetInvites()
+		if err != nil {
+			respondWithError(c, err, "Failed to fetch invites")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"invites": invites})
+	})
+
+	r.POST("/api/invites/:inviteID/accept", func(c *gin.Context) {
+		db := userDB(c, gormDB)
+		inviteID, err := strconv.ParseUint(c.Param("inviteID"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invite ID"})
+			return
+		}
+
+		member, err := db.AcceptInvite(uint(inviteID))
+		if err != nil {
+			respondWithError(c, err, "Failed to accept invite")
+			return
+		}
+		deckEvent(db, "deck.changed", member.DeckID, 0).send()
+
+		c.JSON(http.StatusOK, gin.H{"member": member})
+	})
+
+	r.DELETE("/api/invites/:inviteID", func(c *gin.Context) {
+		db := userDB(c, gormDB)
+		inviteID, err := strconv.ParseUint(c.Param("inviteID"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invite ID"})
+			return
+		}
+
+		if err := db.DeclineInvite(uint(inviteID)); err != nil {
+			respondWithError(c, err, "Failed to decline invite")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Invite declined"})
+	})
+}
@@ -0,0 +1,272 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+	"webproject/database"
+	"webproject/spacedrepetition"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultStatsWindowDays = 30
+	maxStatsWindowDays     = 365
+	defaultForecastDays    = 30
+	maxForecastDays        = 365
+	defaultActivityDays    = 365
+	maxActivityDays        = 3 * 366
+)
+
+// Days follow the configured timezone and rollover hour, the timezone can be
+// overridden per request with ?tz=Europe/Madrid
+func queryDayBoundary(c *gin.Context, gormDB *database.GormDB) (database.DayBoundary, error) {
+	boundary, err := gormDB.GetDayBoundary()
+	if err != nil {
+		return boundary, err
+	}
+	if name := c.Query("tz"); name != "" {
+		loc, err := time.LoadLocation(name)
+		if err != nil {
+			return boundary, err
+		}
+		boundary.Location = loc
+	}
+	return boundary, nil
+}
+
+func RegisterStatsRoutes(r *gin.Engine, gormDB *database.GormDB) {
+
+	r.GET("/api/deck/:deckID/stats", func(c *gin.Context) {
+		db := userDB(c, gormDB)
+		deckIDStr, err := strconv.ParseUint(c.Param("deckID"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid deck ID"})
+			return
+		}
+		deckID := uint(deckIDStr)
+		if !deckAccess(c, db, deckID, "viewer") {
+			return
+		}
+
+		boundary, err := queryDayBoundary(c, db)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid timezone"})
+			return
+		}
+
+		window := defaultStatsWindowDays
+		if q := c.Query("days"); q != "" {
+			if n, err := strconv.Atoi(q); err == nil && n > 0 {
+				window = n
+			}
+		}
+
+		deck, err := db.GetDeckByID(deckID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "deck not found"})
+			return
+		}
+
+		stats, err := db.GetDeckStats(deckID, boundary, window)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to compute deck stats",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"deck":  deck,
+			"stats": stats,
+		})
+	})
+
+	respondWithForecast := func(c *gin.Context, deckID uint) {
+		db := userDB(c, gormDB)
+		boundary, err := queryDayBoundary(c, db)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid timezone"})
+			return
+		}
+
+		days := defaultForecastDays
+		if q := c.Query("days"); q != "" {
+			if n, err := strconv.Atoi(q); err == nil && n > 0 && n <= maxForecastDays {
+				days = n
+			}
+		}
+
+		newPerDay := 0
+		if q := c.Query("new"); q != "" {
+			if n, err := strconv.Atoi(q); err == nil && n > 0 {
+				newPerDay = n
+			}
+		}
+
+		forecast, err := db.GetForecast(deckID, days, boundary, newPerDay)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to compute forecast",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		total := 0
+		for _, day := range forecast {
+			total += day.Reviews
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"days":          forecast,
+			"total_reviews": total,
+		})
+	}
+
+	r.GET("/api/deck/:deckID/forecast", func(c *gin.Context) {
+		db := userDB(c, gormDB)
+		deckIDStr, err := strconv.ParseUint(c.Param("deckID"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid deck ID"})
+			return
+		}
+		deckID := uint(deckIDStr)
+		if !deckAccess(c, db, deckID, "viewer") {
+			return
+		}
+
+		if _, err := db.GetDeckByID(deckID); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "deck not found"})
+			return
+		}
+
+		respondWithForecast(c, deckID)
+	})
+
+	r.GET("/api/forecast", func(c *gin.Context) {
+		respondWithForecast(c, 0)
+	})
+
+	// from and to are inclusive YYYY-MM-DD study days
+	r.GET("/api/stats/activity", func(c *gin.Context) {
+		db := userDB(c, gormDB)
+		boundary, err := queryDayBoundary(c, db)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid timezone"})
+			return
+		}
+
+		var deckID uint
+		if q := c.Query("deck"); q != "" {
+			id, err := strconv.ParseUint(q, 10, 32)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid deck ID"})
+				return
+			}
+			deckID = uint(id)
+		}
+
+		to := boundary.StartOfDay(db.Now())
+		if q := c.Query("to"); q != "" {
+			if to, err = boundary.ParseDate(q); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "to must be a YYYY-MM-DD date"})
+				return
+			}
+		}
+		from := to.AddDate(0, 0, -(defaultActivityDays - 1))
+		if q := c.Query("from"); q != "" {
+			if from, err = boundary.ParseDate(q); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "from must be a YYYY-MM-DD date"})
+				return
+			}
+		}
+		end := to.AddDate(0, 0, 1)
+
+		if !from.Before(end) || from.AddDate(0, 0, maxActivityDays).Before(end) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "from must be before to and the range at most three years",
+			})
+			return
+		}
+
+		days, err := db.GetActivity(deckID, from, end, boundary)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to fetch activity",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		streaks, err := db.GetStreaks(boundary)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to compute streaks",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"days":    days,
+			"streaks": streaks,
+		})
+	})
+
+	respondWithCalibration := func(c *gin.Context, deckID uint) {
+		db := userDB(c, gormDB)
+		boundary, err := queryDayBoundary(c, db)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid timezone"})
+			return
+		}
+
+		// days=0 or no days means all history
+		var since time.Time
+		if q := c.Query("days"); q != "" {
+			if n, err := strconv.Atoi(q); err == nil && n > 0 {
+				since = db.Now().AddDate(0, 0, -n)
+			}
+		}
+
+		samples, err := db.GetCalibrationSamples(deckID, since, boundary)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to fetch review history",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"calibration": spacedrepetition.Calibrate(samples),
+		})
+	}
+
+	r.GET("/api/deck/:deckID/calibration", func(c *gin.Context) {
+		db := userDB(c, gormDB)
+		deckIDStr, err := strconv.ParseUint(c.Param("deckID"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid deck ID"})
+			return
+		}
+		deckID := uint(deckIDStr)
+		if !deckAccess(c, db, deckID, "viewer") {
+			return
+		}
+
+		if _, err := db.GetDeckByID(deckID); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "deck not found"})
+			return
+		}
+
+		respondWithCalibration(c, deckID)
+	})
+
+	r.GET("/api/stats/calibration", func(c *gin.Context) {
+		respondWithCalibration(c, 0)
+	})
+}
@@ -0,0 +1,49 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"webproject/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+func RegisterSyncRoutes(r *gin.Engine, gormDB *database.GormDB) {
+
+	// offline clients send what they changed since their last token and get
+	// back what changed on the server, see database.Sync for how conflicts
+	// are settled
+	r.POST("/api/sync", func(c *gin.Context) {
+		db := userDB(c, gormDB)
+		var json database.SyncRequest
+		if err := c.ShouldBindJSON(&json); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "invalid JSON payload",
+				"details": err.Error(),
+			})
+			return
+		}
+		if scope := c.GetString("scope"); len(json.Cards) > 0 && !database.ScopeAllows(scope, "edit") {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": "this token's " + scope + " scope doesn't allow card changes, it needs edit",
+			})
+			return
+		}
+
+		result, err := db.Sync(json)
+		if errors.Is(err, database.ErrInvalidSyncToken) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to sync",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		publishCardChanges(db, result.Changes)
+		c.JSON(http.StatusOK, result)
+	})
+}
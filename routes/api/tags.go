@@ -0,0 +1,83 @@
+package api
+
+import (
+	"net/http"
+	"webproject/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+func RegisterTagsRoutes(r *gin.Engine, gormDB *database.GormDB) {
+
+	r.GET("/api/tags", func(c *gin.Context) {
+		db := userDB(c, gormDB)
+		tags, err := db.GetTags()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to fetch tags",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"tags": tags})
+	})
+
+	bulkTagRoute := func(update func(cardIDs []uint, tags []string) error) gin.HandlerFunc {
+		return func(c *gin.Context) {
+			var json struct {
+				CardIDs []uint   `json:"card_ids"`
+				Tags    []string `json:"tags"`
+			}
+			if err := c.ShouldBindJSON(&json); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error":   "invalid JSON payload",
+					"details": err.Error(),
+				})
+				return
+			}
+
+			tags := database.NormalizeTags(json.Tags)
+			if len(json.CardIDs) == 0 || len(tags) == 0 {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error": "card_ids and tags can't be empty",
+				})
+				return
+			}
+
+			editable, err := userDB(c, gormDB).CanEditCards(json.CardIDs)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error":   "Failed to check access",
+					"details": err.Error(),
+				})
+				return
+			}
+			if !editable {
+				c.JSON(http.StatusForbidden, gin.H{
+					"error": "tags can only be changed on cards of decks you can edit",
+				})
+				return
+			}
+
+			if err := update(json.CardIDs, tags); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error":   "Failed to update tags",
+					"details": err.Error(),
+				})
+				return
+			}
+			for _, id := range json.CardIDs {
+				deckEvent(userDB(c, gormDB), "card.updated", 0, id).send()
+			}
+
+			c.JSON(http.StatusOK, gin.H{
+				"card_ids": json.CardIDs,
+				"tags":     tags,
+			})
+		}
+	}
+
+	r.POST("/api/cards/tags/add", bulkTagRoute(gormDB.AddTagsToCards))
+	r.POST("/api/cards/tags/remove", bulkTagRoute(gormDB.RemoveTagsFromCards))
+}
@@ -0,0 +1,73 @@
+package api
+
+import (
+	"sync"
+	"time"
+	"webproject/database"
+)
+
+// servedCards remembers when each card was last shown to each user so
+// answers can be timed when the client doesn't report a duration itself.
+type servedCards struct {
+	mu     sync.Mutex
+	served map[servedCard]time.Time
+	marks  int // since the last sweep
+}
+
+type servedCard struct {
+	userID uint
+	cardID uint
+}
+
+var served = &servedCards{served: map[servedCard]time.Time{}}
+
+const (
+	// entries older than this are stale sessions and not worth keeping
+	servedCardTTL = time.Hour
+	// cards shown but never answered are swept out this often, take ignores
+	// stale ones in between
+	servedCardSweepEvery = 1000
+)
+
+func (s *servedCards) mark(gormDB *database.GormDB, cardID uint) {
+	now := gormDB.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.marks++
+	if s.marks >= servedCardSweepEvery {
+		s.marks = 0
+		for key, at := range s.served {
+			if now.Sub(at) > servedCardTTL {
+				delete(s.served, key)
+			}
+		}
+	}
+	s.served[servedCard{gormDB.UserID, cardID}] = now
+}
+
+func (s *servedCards) take(gormDB *database.GormDB, cardID uint) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := servedCard{gormDB.UserID, cardID}
+	at, ok := s.served[key]
+	if !ok {
+		return 0
+	}
+	delete(s.served, key)
+	elapsed := gormDB.Now().Sub(at)
+	if elapsed > servedCardTTL {
+		return 0
+	}
+	return elapsed
+}
+
+// answerDuration prefers the client's own measurement
+func answerDuration(gormDB *database.GormDB, cardID uint, reportedMs int64) time.Duration {
+	measured := served.take(gormDB, cardID)
+	if reportedMs > 0 {
+		return time.Duration(reportedMs) * time.Millisecond
+	}
+	return measured
+}
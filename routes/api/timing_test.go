@@ -0,0 +1,36 @@
+package api
+
+import (
+	"testing"
+	"time"
+	"webproject/clock"
+	"webproject/database"
+)
+
+func TestServedCardsForgetStaleEntries(t *testing.T) {
+	fake := clock.NewFake(time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC))
+	db := &database.GormDB{Clock: fake, UserID: 1}
+	s := &servedCards{served: map[servedCard]time.Time{}}
+
+	s.mark(db, 1)
+	fake.Set(fake.Now().Add(20 * time.Second))
+	if got := s.take(db, 1); got != 20*time.Second {
+		t.Errorf("took %v, want 20s", got)
+	}
+
+	s.mark(db, 2)
+	fake.Set(fake.Now().Add(servedCardTTL + time.Minute))
+	if got := s.take(db, 2); got != 0 {
+		t.Errorf("stale entry took %v, want 0", got)
+	}
+
+	// shown but never answered, swept out by a later mark
+	s.mark(db, 3)
+	fake.Set(fake.Now().Add(servedCardTTL + time.Minute))
+	for i := range servedCardSweepEvery {
+		s.mark(db.ForUser(2), uint(100+i))
+	}
+	if _, ok := s.served[servedCard{1, 3}]; ok {
+		t.Error("stale entry wasn't swept")
+	}
+}
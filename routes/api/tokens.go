@@ -0,0 +1,100 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"webproject/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+func RegisterTokensRoutes(r *gin.Engine, gormDB *database.GormDB) {
+
+	r.GET("/api/tokens", func(c *gin.Context) {
+		db := userDB(c, gormDB)
+		tokens, err := db.ListAPITokens()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to fetch API tokens",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"tokens": tokens})
+	})
+
+	// the token itself is only in this response, it can't be looked up later
+	r.POST("/api/tokens", func(c *gin.Context) {
+		db := userDB(c, gormDB)
+		var json struct {
+			Name          string `json:"name"`
+			Scope         string `json:"scope"`
+			ExpiresInDays int    `json:"expires_in_days"` // 0 never expires
+		}
+		if err := c.ShouldBindJSON(&json); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "invalid JSON payload",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		json.Name = strings.TrimSpace(json.Name)
+		if json.Name == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+			return
+		}
+		if json.ExpiresInDays < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "expires_in_days can't be negative"})
+			return
+		}
+		var expiresAt *time.Time
+		if json.ExpiresInDays > 0 {
+			t := db.Now().AddDate(0, 0, json.ExpiresInDays)
+			expiresAt = &t
+		}
+
+		token, apiToken, err := db.CreateAPIToken(json.Name, json.Scope, expiresAt)
+		if errors.Is(err, database.ErrUnknownScope) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to create API token",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"token": token, "api_token": apiToken})
+	})
+
+	r.DELETE("/api/tokens/:tokenID", func(c *gin.Context) {
+		db := userDB(c, gormDB)
+		tokenID, err := strconv.ParseUint(c.Param("tokenID"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid token ID"})
+			return
+		}
+
+		err = db.DeleteAPIToken(uint(tokenID))
+		if errors.Is(err, database.ErrTokenNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to revoke API token",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Token revoked"})
+	})
+}
@@ -0,0 +1,84 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"webproject/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+func RegisterTrashRoutes(r *gin.Engine, gormDB *database.GormDB) {
+
+	// deleted cards and decks the user can still restore, and when each of
+	// them will be purged
+	r.GET("/api/trash", func(c *gin.Context) {
+		db := userDB(c, gormDB)
+		trash, err := db.GetTrash()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to fetch the trash",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"decks":          trash.Decks,
+			"cards":          trash.Cards,
+			"retention_days": int(database.TrashRetention.Hours() / 24),
+		})
+	})
+
+	r.POST("/api/trash/card/:cardID/restore", func(c *gin.Context) {
+		db := userDB(c, gormDB)
+		cardID, err := strconv.ParseUint(c.Param("cardID"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid card ID"})
+			return
+		}
+
+		card, err := db.RestoreCard(uint(cardID))
+		if errors.Is(err, database.ErrNotInTrash) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "card is not in the trash"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to restore card",
+				"details": err.Error(),
+			})
+			return
+		}
+		// to everyone else the card is new again
+		deckEvent(db, "card.created", 0, card.ID).send()
+
+		c.JSON(http.StatusOK, card)
+	})
+
+	r.POST("/api/trash/deck/:deckID/restore", func(c *gin.Context) {
+		db := userDB(c, gormDB)
+		deckID, err := strconv.ParseUint(c.Param("deckID"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid deck ID"})
+			return
+		}
+
+		deck, err := db.RestoreDeck(uint(deckID))
+		if errors.Is(err, database.ErrNotInTrash) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "deck is not in the trash"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to restore deck",
+				"details": err.Error(),
+			})
+			return
+		}
+		deckEvent(db, "deck.changed", deck.ID, 0).send()
+
+		c.JSON(http.StatusOK, deck)
+	})
+}
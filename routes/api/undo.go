@@ -0,0 +1,86 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"webproject/database"
+	"webproject/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func RegisterUndoRoutes(r *gin.Engine, gormDB *database.GormDB) {
+
+	// takes back the latest answer in the deck, for misclicks. The client can
+	// send its session's remaining cards, the card comes back at their head.
+	r.POST("/api/deck/:deckID/undo", func(c *gin.Context) {
+		db := userDB(c, gormDB)
+		deckIDStr, err := strconv.ParseUint(c.Param("deckID"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid deck ID"})
+			return
+		}
+		deckID := uint(deckIDStr)
+		if !deckAccess(c, db, deckID, "viewer") {
+			return
+		}
+
+		var payload struct {
+			Cards []models.Card `json:"cards"`
+		}
+		if c.Request.ContentLength != 0 {
+			if err := c.ShouldBindJSON(&payload); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error":   "invalid JSON payload",
+					"details": err.Error(),
+				})
+				return
+			}
+		}
+
+		card, log, err := db.UndoLastAnswer(deckID)
+		if errors.Is(err, database.ErrNothingToUndo) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to undo answer",
+				"details": err.Error(),
+			})
+			return
+		}
+		reviewEvent(db, "review.undone", log.DeckID, card.ID).send()
+
+		cards := []models.Card{card}
+		for _, queued := range payload.Cards {
+			if queued.ID != card.ID {
+				cards = append(cards, queued)
+			}
+		}
+
+		served.mark(db, card.ID)
+		choices, err := db.GetShuffledChoicesForCard(deckID, card)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Error while trying to get multiple choice options",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"undone": gin.H{
+				"card_id":     log.CardID,
+				"reviewed_at": log.ReviewedAt,
+				"answer":      log.Answer,
+				"correct":     log.Correct,
+			},
+			"cards":      cards,
+			"current":    card,
+			"choices":    choices,
+			"cards_left": len(cards),
+		})
+	})
+}
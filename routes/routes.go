@@ -7,10 +7,31 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func RegisterAll(r *gin.Engine, gormDB *database.GormDB) {
+func RegisterAll(r *gin.Engine, gormDB *database.GormDB, backups *database.Backups) {
+	api.RegisterAuthRoutes(r, gormDB)
+
+	// everything registered from here on needs a logged in user
+	r.Use(api.RequireUser(gormDB))
 
 	api.RegisterDecksRoutes(r, gormDB)
 	api.RegisterReviewRoutes(r, gormDB)
 	api.RegisterSetupRoutes(r, gormDB)
+	api.RegisterTrashRoutes(r, gormDB)
 	api.RegisterLearningRoutes(r, gormDB)
+	api.RegisterCramRoutes(r, gormDB)
+	api.RegisterUndoRoutes(r, gormDB)
+	api.RegisterOptionsRoutes(r, gormDB)
+	api.RegisterStatsRoutes(r, gormDB)
+	api.RegisterSettingsRoutes(r, gormDB)
+	api.RegisterHistoryRoutes(r, gormDB)
+	api.RegisterTagsRoutes(r, gormDB)
+	api.RegisterSearchRoutes(r, gormDB)
+	api.RegisterFilteredDecksRoutes(r, gormDB)
+	api.RegisterTokensRoutes(r, gormDB)
+	api.RegisterSharingRoutes(r, gormDB)
+	api.RegisterClassesRoutes(r, gormDB)
+	api.RegisterAssignmentsRoutes(r, gormDB)
+	api.RegisterSyncRoutes(r, gormDB)
+	api.RegisterEventsRoutes(r, gormDB)
+	api.RegisterBackupRoutes(r, gormDB, backups)
 }
@@ -0,0 +1,135 @@
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"webproject/clock"
+	"webproject/database"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+type testServer struct {
+	t      *testing.T
+	router *gin.Engine
+	db     *gorm.DB
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")),
+		&gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := database.Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+	gormDB := &database.GormDB{DB: db, Clock: clock.Real{}}
+	r := gin.New()
+	RegisterAll(r, gormDB, &database.Backups{DB: db, Dir: t.TempDir()})
+	return &testServer{t: t, router: r, db: db}
+}
+
+// do sends the request with the session cookie, if any, and returns the
+// response
+func (s *testServer) do(session *http.Cookie, method string, path string, body string) *httptest.ResponseRecorder {
+	s.t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if session != nil {
+		req.AddCookie(session)
+	}
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	return w
+}
+
+func (s *testServer) register(username string) *http.Cookie {
+	s.t.Helper()
+	w := s.do(nil, http.MethodPost, "/api/register", `{"username":"`+username+`","password":"password1"}`)
+	if w.Code != http.StatusCreated {
+		s.t.Fatalf("register %s: %d %s", username, w.Code, w.Body)
+	}
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == "session" {
+			return cookie
+		}
+	}
+	s.t.Fatal("register didn't start a session")
+	return nil
+}
+
+func TestOtherUsersDeckAndCardRoutesAreNotFound(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.register("alice")
+	bob := s.register("bob")
+
+	if w := s.do(alice, http.MethodPost, "/api/createdeck", `{"name":"French"}`); w.Code != http.StatusCreated {
+		t.Fatalf("createdeck: %d %s", w.Code, w.Body)
+	}
+	if w := s.do(alice, http.MethodPost, "/api/deck/1/createcard", `{"question":"chat","answer":"cat"}`); w.Code != http.StatusCreated {
+		t.Fatalf("createcard: %d %s", w.Code, w.Body)
+	}
+
+	tests := []struct {
+		method string
+		path   string
+		body   string
+	}{
+		{http.MethodGet, "/api/deck/1", ""},
+		{http.MethodGet, "/api/deck/1/cards", ""},
+		{http.MethodGet, "/api/deck/1/learning", ""},
+		{http.MethodGet, "/api/deck/1/review", ""},
+		{http.MethodPost, "/api/deck/1/createcard", `{"question":"chien","answer":"dog"}`},
+		{http.MethodPut, "/api/card/1/edit", `{"question":"hijacked","answer":"x"}`},
+		{http.MethodGet, "/api/card/1/history", ""},
+		{http.MethodDelete, "/api/card/1/delete", ""},
+		{http.MethodDelete, "/api/deck/1", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			if w := s.do(bob, tt.method, tt.path, tt.body); w.Code != http.StatusNotFound {
+				t.Errorf("got %d %s, want 404", w.Code, w.Body)
+			}
+		})
+	}
+
+	w := s.do(alice, http.MethodGet, "/api/deck/1/cards", "")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"Question":"chat"`) {
+		t.Errorf("alice's card changed: %d %s", w.Code, w.Body)
+	}
+}
+
+func TestWorkloadCapsTheWork(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.register("alice")
+	if w := s.do(alice, http.MethodPost, "/api/createdeck", `{"name":"French"}`); w.Code != http.StatusCreated {
+		t.Fatalf("createdeck: %d %s", w.Code, w.Body)
+	}
+
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{"days=7&new=5", http.StatusOK},
+		{"new=100", http.StatusOK},
+		{"new=101", http.StatusBadRequest},
+		{"new=lots", http.StatusBadRequest},
+		{"retention=0.8" + strings.Repeat("&retention=0.8", 10), http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			if w := s.do(alice, http.MethodGet, "/api/deck/1/workload?"+tt.query, ""); w.Code != tt.want {
+				t.Errorf("got %d %s, want %d", w.Code, w.Body, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+	"webproject/database"
+	"webproject/routes/api"
+)
+
+// anything a route takes as a parameter, filled in with an ID nothing has
+var routeParam = regexp.MustCompile(`[:*][^/]+`)
+
+// token creates an API token with the scope for the logged in user
+func (s *testServer) token(session *http.Cookie, scope string) string {
+	s.t.Helper()
+	w := s.do(session, http.MethodPost, "/api/tokens", `{"name":"`+scope+`","scope":"`+scope+`"}`)
+	var created struct {
+		Token string `json:"token"`
+	}
+	if w.Code != http.StatusCreated || json.Unmarshal(w.Body.Bytes(), &created) != nil {
+		s.t.Fatalf("create %s token: %d %s", scope, w.Code, w.Body)
+	}
+	return created.Token
+}
+
+// streamRecorder lets the event stream run, gin's Stream wants a
+// CloseNotifier that httptest doesn't have
+type streamRecorder struct {
+	*httptest.ResponseRecorder
+}
+
+func (streamRecorder) CloseNotify() <-chan bool {
+	return make(chan bool)
+}
+
+// refused sends an empty request with the API token and reports whether the
+// token's scope was turned away. The event stream is cut off quickly.
+func (s *testServer) refused(token string, method string, path string) bool {
+	s.t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	req := httptest.NewRequest(method, path, strings.NewReader("")).WithContext(ctx)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := streamRecorder{httptest.NewRecorder()}
+	s.router.ServeHTTP(w, req)
+	return w.Code == http.StatusForbidden && strings.Contains(w.Body.String(), "scope doesn't allow")
+}
+
+func TestRouteScopesAreRegisteredRoutes(t *testing.T) {
+	s := newTestServer(t)
+	if err := api.CheckRouteScopes(s.router.Routes()); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTokenScopes(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.register("alice")
+	if _, err := database.MakeAdmin(s.db, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	tokens := map[string]string{}
+	for _, scope := range database.TokenScopes {
+		tokens[scope] = s.token(alice, scope)
+	}
+
+	t.Run("examples", func(t *testing.T) {
+		tests := []struct {
+			scope   string
+			method  string
+			path    string
+			allowed bool
+		}{
+			{"read", http.MethodGet, "/api/decks", true},
+			{"read", http.MethodPost, "/api/createdeck", false},
+			{"read", http.MethodPost, "/api/deck/1/learning", false},
+			{"study", http.MethodPost, "/api/deck/1/learning", true},
+			{"study", http.MethodPut, "/api/card/1/edit", false},
+			{"edit", http.MethodPut, "/api/card/1/edit", true},
+			{"edit", http.MethodGet, "/api/tokens", false},
+			{"admin", http.MethodGet, "/api/tokens", true},
+			{"edit", http.MethodGet, "/api/admin/backups", false},
+			{"admin", http.MethodGet, "/api/admin/backups", true},
+		}
+		for _, tt := range tests {
+			if refused := s.refused(tokens[tt.scope], tt.method, tt.path); refused == tt.allowed {
+				t.Errorf("%s token on %s %s: refused %v, want allowed %v", tt.scope, tt.method, tt.path, refused, tt.allowed)
+			}
+		}
+	})
+
+	// every route behind RequireUser turns away exactly the scopes below
+	// what it needs
+	public := map[string]bool{"POST /api/register": true, "POST /api/login": true, "POST /api/logout": true}
+	for _, route := range s.router.Routes() {
+		if public[route.Method+" "+route.Path] {
+			continue
+		}
+		need := api.RouteScope(route.Method, route.Path)
+		path := routeParam.ReplaceAllString(route.Path, "999999")
+		t.Run(route.Method+" "+route.Path, func(t *testing.T) {
+			for _, scope := range database.TokenScopes {
+				want := !database.ScopeAllows(scope, need)
+				if refused := s.refused(tokens[scope], route.Method, path); refused != want {
+					t.Errorf("%s token: refused %v, want %v as it needs %s", scope, refused, want, need)
+				}
+			}
+		})
+	}
+}
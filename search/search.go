@@ -0,0 +1,268 @@
+// Package search parses the card search language, for example
+//
+//	tag:verbs (lapses>=3 OR ease<2) -stage:learning "to be"
+//
+// Terms next to each other must all match, OR and parentheses group them, and
+// a leading - or NOT negates a term.
+package search
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type Node interface{ node() }
+
+type And struct{ Children []Node }
+type Or struct{ Children []Node }
+type Not struct{ Child Node }
+
+// Term is a single condition. Field is "text" for bare words and Op is ":"
+// for plain matches. Number holds the parsed value of numeric fields, and
+// for due, added and lapsed it is a number of days.
+type Term struct {
+	Field  string
+	Op     string
+	Value  string
+	Number int
+}
+
+func (And) node()  {}
+func (Or) node()   {}
+func (Not) node()  {}
+func (Term) node() {}
+
+type fieldKind int
+
+const (
+	textField fieldKind = iota
+	numberField
+	daysField
+)
+
+var fields = map[string]fieldKind{
+	"text":      textField,
+	"question":  textField,
+	"answer":    textField,
+	"tag":       textField,
+	"deck":      textField,
+	"stage":     textField,
+	"ease":      numberField,
+	"lapses":    numberField,
+	"correct":   numberField,
+	"incorrect": numberField,
+	"due":       daysField,
+	"added":     daysField,
+	"lapsed":    daysField,
+}
+
+var termPattern = regexp.MustCompile(`^([a-z]+)(<=|>=|!=|:|=|<|>)(.*)$`)
+
+// Parse returns nil for an empty query, which matches every card.
+func Parse(query string) (Node, error) {
+	tokens, err := tokenize(query)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+
+	p := &parser{tokens: tokens}
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q", p.tokens[p.pos].text)
+	}
+	return node, nil
+}
+
+type token struct {
+	text   string
+	quoted bool // quoted tokens are never operators
+}
+
+func tokenize(query string) ([]token, error) {
+	var tokens []token
+	var current strings.Builder
+	quoted, inQuotes := false, false
+
+	flush := func() {
+		if current.Len() > 0 || quoted {
+			tokens = append(tokens, token{text: current.String(), quoted: quoted})
+		}
+		current.Reset()
+		quoted = false
+	}
+
+	for _, r := range query {
+		switch {
+		case r == '"':
+			inQuotes = !inQuotes
+			quoted = true
+		case inQuotes:
+			current.WriteRune(r)
+		case r == ' ' || r == '\t' || r == '\n':
+			flush()
+		case r == '(' || r == ')':
+			flush()
+			tokens = append(tokens, token{text: string(r)})
+		case r == '-' && current.Len() == 0 && !quoted:
+			tokens = append(tokens, token{text: "-"})
+		default:
+			current.WriteRune(r)
+		}
+	}
+	if inQuotes {
+		return nil, fmt.Errorf("unterminated quote")
+	}
+	flush()
+	return tokens, nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() (token, bool) {
+	if p.pos >= len(p.tokens) {
+		return token{}, false
+	}
+	return p.tokens[p.pos], true
+}
+
+func isKeyword(t token, keyword string) bool {
+	return !t.quoted && strings.EqualFold(t.text, keyword)
+}
+
+func (p *parser) parseOr() (Node, error) {
+	first, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	children := []Node{first}
+	for {
+		t, ok := p.peek()
+		if !ok || !isKeyword(t, "or") {
+			break
+		}
+		p.pos++
+		next, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, next)
+	}
+	if len(children) == 1 {
+		return first, nil
+	}
+	return Or{Children: children}, nil
+}
+
+func (p *parser) parseAnd() (Node, error) {
+	var children []Node
+	for {
+		t, ok := p.peek()
+		if !ok || isKeyword(t, "or") || (t.text == ")" && !t.quoted) {
+			break
+		}
+		if isKeyword(t, "and") {
+			p.pos++
+			continue
+		}
+		child, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, child)
+	}
+	switch len(children) {
+	case 0:
+		return nil, fmt.Errorf("expected a search term")
+	case 1:
+		return children[0], nil
+	}
+	return And{Children: children}, nil
+}
+
+func (p *parser) parseUnary() (Node, error) {
+	t, ok := p.peek()
+	if !ok {
+		return nil, fmt.Errorf("expected a search term")
+	}
+	p.pos++
+
+	if !t.quoted {
+		switch {
+		case t.text == "-" || isKeyword(t, "not"):
+			child, err := p.parseUnary()
+			if err != nil {
+				return nil, err
+			}
+			return Not{Child: child}, nil
+		case t.text == "(":
+			node, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			if closing, ok := p.peek(); !ok || closing.text != ")" || closing.quoted {
+				return nil, fmt.Errorf("missing closing parenthesis")
+			}
+			p.pos++
+			return node, nil
+		}
+	}
+
+	return parseTerm(t)
+}
+
+func parseTerm(t token) (Node, error) {
+	m := termPattern.FindStringSubmatch(t.text)
+	if t.quoted || m == nil {
+		return Term{Field: "text", Op: ":", Value: t.text}, nil
+	}
+
+	field, op, value := m[1], m[2], m[3]
+	kind, known := fields[field]
+	if !known {
+		return nil, fmt.Errorf("unknown field %q", field)
+	}
+	if value == "" {
+		return nil, fmt.Errorf("%s%s needs a value", field, op)
+	}
+	if op == "=" {
+		op = ":"
+	}
+
+	term := Term{Field: field, Op: op, Value: value}
+	switch kind {
+	case textField:
+		if op != ":" {
+			return nil, fmt.Errorf("%s only supports %s:", field, field)
+		}
+	case numberField:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s needs a whole number", field)
+		}
+		term.Number = n
+	case daysField:
+		n, err := strconv.Atoi(strings.TrimSuffix(value, "d"))
+		if err != nil {
+			return nil, fmt.Errorf("%s needs a number of days like 7d", field)
+		}
+		if (field == "added" || field == "lapsed") && op != ":" {
+			return nil, fmt.Errorf("%s only supports %s:Nd", field, field)
+		}
+		if field == "due" && (op == ":" || op == "!=") {
+			return nil, fmt.Errorf("due needs a comparison like due<7d")
+		}
+		term.Number = n
+	}
+	return term, nil
+}
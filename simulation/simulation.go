@@ -0,0 +1,282 @@
+package simulation
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"time"
+	"webproject/clock"
+	"webproject/database"
+	"webproject/models"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+type Config struct {
+	Days             int
+	NewCardsPerDay   int     // learning session limit, same as ?limit= on /learning
+	ReviewsPerDay    int     // 0 means every due card is reviewed
+	LearnAccuracy    float64 // chance of answering a learning card correctly
+	InitialStability float64 // days a freshly learned card stays at 90% recall
+	Seed             uint64
+	Start            time.Time
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Days:             30,
+		NewCardsPerDay:   10,
+		LearnAccuracy:    0.8,
+		InitialStability: 1,
+		Seed:             1,
+		Start:            time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC),
+	}
+}
+
+type DayReport struct {
+	Day             int       `json:"day"`
+	Date            time.Time `json:"date"`
+	NewCards        int       `json:"new_cards"`
+	LearningAnswers int       `json:"learning_answers"`
+	Reviews         int       `json:"reviews"`
+	FirstReviews    int       `json:"first_reviews"`
+	FirstCorrect    int       `json:"first_correct"`
+}
+
+type Report struct {
+	Days                 []DayReport `json:"days"`
+	TotalReviews         int         `json:"total_reviews"`
+	AverageReviewsPerDay float64     `json:"average_reviews_per_day"`
+	MaxReviewsPerDay     int         `json:"max_reviews_per_day"`
+	Retention            float64     `json:"retention"`
+	CardsLearned         int         `json:"cards_learned"`
+}
+
+// NewSandbox copies cards and deck options into a private in-memory database
+// driven by a fake clock, so a simulation never touches real study data.
+func NewSandbox(cards []models.Card, options models.DeckOptions, start time.Time) (*database.GormDB, *clock.Fake, uint, error) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	sqlDB.SetMaxOpenConns(1)
+
+	if err := database.Migrate(db); err != nil {
+		return nil, nil, 0, err
+	}
+
+	fake := clock.NewFake(start)
+	gormDB := &database.GormDB{DB: db, Clock: fake}
+
+	deck := models.Deck{Name: "simulation"}
+	if err := db.Create(&deck).Error; err != nil {
+		return nil, nil, 0, err
+	}
+
+	options.ID = 0
+	options.DeckID = deck.ID
+	if err := gormDB.SaveDeckOptions(options); err != nil {
+		return nil, nil, 0, err
+	}
+
+	for _, card := range cards {
+		// without progress the card starts out learning, due from start
+		fresh := models.Card{
+			DeckID:      deck.ID,
+			Question:    card.Question,
+			Answer:      card.Answer,
+			Extra:       card.Extra,
+			CardCreated: start,
+		}
+		if err := gormDB.CreateCard(fresh); err != nil {
+			return nil, nil, 0, err
+		}
+	}
+
+	return gormDB, fake, deck.ID, nil
+}
+
+func Close(gormDB *database.GormDB) error {
+	sqlDB, err := gormDB.DB.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
+
+// SyntheticCards is used when there is no real deck to replay.
+func SyntheticCards(n int) []models.Card {
+	cards := make([]models.Card, n)
+	for i := range cards {
+		cards[i] = models.Card{
+			Question: fmt.Sprintf("question %d", i+1),
+			Answer:   fmt.Sprintf("answer %d", i+1),
+		}
+	}
+	return cards
+}
+
+// Run replays a synthetic learner against the deck one day at a time, going
+// through the same GormDB calls as the learning and review endpoints.
+func Run(gormDB *database.GormDB, fake *clock.Fake, deckID uint, cfg Config) (Report, error) {
+	l := &learner{
+		rng:    rand.New(rand.NewPCG(cfg.Seed, cfg.Seed^0x9e3779b97f4a7c15)),
+		cfg:    cfg,
+		memory: map[uint]*trace{},
+	}
+
+	var report Report
+	var firstReviews, firstCorrect int
+
+	for day := 0; day < cfg.Days; day++ {
+		fake.Set(cfg.Start.Add(time.Duration(day) * 24 * time.Hour))
+		today := DayReport{Day: day + 1, Date: fake.Now()}
+
+		if err := l.learningSession(gormDB, fake, deckID, &today); err != nil {
+			return report, err
+		}
+		if err := l.reviewSession(gormDB, fake, deckID, &today); err != nil {
+			return report, err
+		}
+
+		report.Days = append(report.Days, today)
+		report.TotalReviews += today.Reviews
+		report.MaxReviewsPerDay = max(report.MaxReviewsPerDay, today.Reviews)
+		firstReviews += today.FirstReviews
+		firstCorrect += today.FirstCorrect
+	}
+
+	if cfg.Days > 0 {
+		report.AverageReviewsPerDay = float64(report.TotalReviews) / float64(cfg.Days)
+	}
+	if firstReviews > 0 {
+		report.Retention = float64(firstCorrect) / float64(firstReviews)
+	}
+
+	learned, err := gormDB.GetReviewCardsByDeckID(deckID)
+	if err != nil {
+		return report, err
+	}
+	report.CardsLearned = len(learned)
+
+	return report, nil
+}
+
+func (l *learner) learningSession(gormDB *database.GormDB, fake *clock.Fake, deckID uint, today *DayReport) error {
+	queue, err := gormDB.GetFirstXCards(deckID, l.cfg.NewCardsPerDay, "learning")
+	if err != nil {
+		return err
+	}
+	today.NewCards = len(queue)
+
+	// a learner who never gets anything right would loop forever
+	maxAnswers := len(queue) * 50
+	for len(queue) > 0 && today.LearningAnswers < maxAnswers {
+		fake.Advance(time.Minute)
+		current := queue[0]
+		correct := l.answer(current, fake.Now())
+		today.LearningAnswers++
+
+		updated, err := gormDB.UpdateLearningCardByID(current.ID, "", correct, 0)
+		if err != nil {
+			return err
+		}
+
+		if updated.Stage == "review" {
+			queue = queue[1:]
+		} else {
+			queue = append(queue[1:], updated)
+		}
+	}
+	return nil
+}
+
+func (l *learner) reviewSession(gormDB *database.GormDB, fake *clock.Fake, deckID uint, today *DayReport) error {
+	seen := map[uint]bool{}
+
+	for l.cfg.ReviewsPerDay <= 0 || today.Reviews < l.cfg.ReviewsPerDay {
+		queue, err := gormDB.GetFirstXCards(deckID, 50, "review")
+		if err != nil {
+			return err
+		}
+		if len(queue) == 0 {
+			return nil
+		}
+
+		for _, current := range queue {
+			if l.cfg.ReviewsPerDay > 0 && today.Reviews >= l.cfg.ReviewsPerDay {
+				return nil
+			}
+			fake.Advance(10 * time.Second)
+			correct := l.answer(current, fake.Now())
+			today.Reviews++
+			if !seen[current.ID] {
+				seen[current.ID] = true
+				today.FirstReviews++
+				if correct {
+					today.FirstCorrect++
+				}
+			}
+
+			if err := gormDB.UpdateReviewCardByID(current.ID, "", correct, 0); err != nil {
+				return err
+			}
+		}
+		// failed cards come back a minute later, like in a real session
+		fake.Advance(time.Minute)
+	}
+	return nil
+}
+
+// The synthetic learner is deliberately simple: each card's memory decays
+// exponentially and is strengthened more by reviews that happen close to
+// the point of forgetting.
+const (
+	successGrowthBase   = 1.5
+	successGrowthBonus  = 10.0
+	lapseStabilityScale = 0.3
+	minStability        = 0.2
+)
+
+type trace struct {
+	stability float64
+	last      time.Time
+}
+
+type learner struct {
+	rng    *rand.Rand
+	cfg    Config
+	memory map[uint]*trace
+}
+
+func (l *learner) answer(card models.Card, now time.Time) bool {
+	t, known := l.memory[card.ID]
+	if !known {
+		correct := l.rng.Float64() < l.cfg.LearnAccuracy
+		if correct {
+			l.memory[card.ID] = &trace{stability: l.cfg.InitialStability, last: now}
+		}
+		return correct
+	}
+
+	elapsedDays := now.Sub(t.last).Hours() / 24
+	recall := math.Pow(0.9, elapsedDays/t.stability)
+	correct := l.rng.Float64() < recall
+
+	if correct {
+		t.stability *= successGrowthBase + successGrowthBonus*(1-recall)
+	} else {
+		t.stability = math.Max(minStability, t.stability*lapseStabilityScale)
+	}
+	t.last = now
+
+	return correct
+}
@@ -0,0 +1,116 @@
+package spacedrepetition
+
+import "fmt"
+
+// CalibrationSample is one review of a card that was due, with the recall
+// probability the scheduler expected at the time.
+type CalibrationSample struct {
+	ElapsedDays float64
+	AgeDays     float64
+	Ease        uint
+	Predicted   float64
+	Recalled    bool
+}
+
+type CalibrationBucket struct {
+	Label     string  `json:"label"` // ranges include the lower bound, "3-7d" is 3 up to but not including 7 days
+	Reviews   int     `json:"reviews"`
+	Observed  float64 `json:"observed_recall"`
+	Predicted float64 `json:"predicted_recall"`
+	// positive when the scheduler is too pessimistic, negative when it is
+	// too aggressive
+	Difference float64 `json:"difference"`
+}
+
+type CalibrationReport struct {
+	Overall      CalibrationBucket   `json:"overall"`
+	Brier        float64             `json:"brier_score"`
+	ByInterval   []CalibrationBucket `json:"by_interval"`
+	ByEase       []CalibrationBucket `json:"by_ease"`
+	ByAge        []CalibrationBucket `json:"by_card_age"`
+	ByPrediction []CalibrationBucket `json:"by_prediction"`
+}
+
+var (
+	intervalBucketDays  = []float64{1, 3, 7, 14, 30, 90}
+	ageBucketDays       = []float64{7, 30, 90, 365}
+	easeBuckets         = []float64{2, 3, 5, 9, 17}
+	predictionBucketsAt = []float64{0.5, 0.7, 0.8, 0.85, 0.9, 0.95}
+)
+
+func Calibrate(samples []CalibrationSample) CalibrationReport {
+	var report CalibrationReport
+
+	report.Overall = summarise("all", samples)
+	for _, s := range samples {
+		y := 0.0
+		if s.Recalled {
+			y = 1
+		}
+		report.Brier += (s.Predicted - y) * (s.Predicted - y)
+	}
+	if len(samples) > 0 {
+		report.Brier /= float64(len(samples))
+	}
+
+	report.ByInterval = bucketBy(samples, intervalBucketDays, "d",
+		func(s CalibrationSample) float64 { return s.ElapsedDays })
+	report.ByEase = bucketBy(samples, easeBuckets, "",
+		func(s CalibrationSample) float64 { return float64(s.Ease) })
+	report.ByAge = bucketBy(samples, ageBucketDays, "d",
+		func(s CalibrationSample) float64 { return s.AgeDays })
+	report.ByPrediction = bucketBy(samples, predictionBucketsAt, "",
+		func(s CalibrationSample) float64 { return s.Predicted })
+
+	return report
+}
+
+// bucketBy splits samples at the given upper bounds, leaving out empty
+// buckets
+func bucketBy(samples []CalibrationSample, bounds []float64, unit string, key func(CalibrationSample) float64) []CalibrationBucket {
+	grouped := make([][]CalibrationSample, len(bounds)+1)
+	for _, s := range samples {
+		i := 0
+		for i < len(bounds) && key(s) >= bounds[i] {
+			i++
+		}
+		grouped[i] = append(grouped[i], s)
+	}
+
+	var buckets []CalibrationBucket
+	for i, group := range grouped {
+		if len(group) == 0 {
+			continue
+		}
+		var label string
+		switch {
+		case i == 0:
+			label = fmt.Sprintf("<%g%s", bounds[0], unit)
+		case i == len(bounds):
+			label = fmt.Sprintf(">=%g%s", bounds[i-1], unit)
+		default:
+			label = fmt.Sprintf("%g-%g%s", bounds[i-1], bounds[i], unit)
+		}
+		buckets = append(buckets, summarise(label, group))
+	}
+	return buckets
+}
+
+func summarise(label string, samples []CalibrationSample) CalibrationBucket {
+	bucket := CalibrationBucket{Label: label, Reviews: len(samples)}
+	if len(samples) == 0 {
+		return bucket
+	}
+
+	var recalled, predicted float64
+	for _, s := range samples {
+		if s.Recalled {
+			recalled++
+		}
+		predicted += s.Predicted
+	}
+	bucket.Observed = recalled / float64(len(samples))
+	bucket.Predicted = predicted / float64(len(samples))
+	bucket.Difference = bucket.Observed - bucket.Predicted
+	return bucket
+}
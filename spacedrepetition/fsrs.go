@@ -0,0 +1,121 @@
+package spacedrepetition
+
+import (
+	"math"
+	"time"
+)
+
+// FSRS-4.5 memory model. Grades follow the usual 1-4 scale; this app only
+// knows right and wrong, which map to Good and Again, with slow correct
+// answers optionally counting as Hard.
+const (
+	GradeAgain = 1
+	GradeHard  = 2
+	GradeGood  = 3
+	GradeEasy  = 4
+
+	fsrsDecay  = -0.5
+	fsrsFactor = 19.0 / 81.0 // makes retrievability 0.9 when elapsed == stability
+
+	minDifficulty = 1.0
+	maxDifficulty = 10.0
+	minStability  = 0.01
+)
+
+var DefaultFSRSWeights = []float64{
+	0.4872, 1.4003, 3.7145, 13.8206, 5.1618, 1.2298, 0.8975, 0.031, 1.6474,
+	0.1367, 1.0461, 2.1072, 0.0793, 0.3246, 1.587, 0.2272, 2.8755,
+}
+
+// lower and upper bounds for each weight, used to keep the optimiser sane
+var fsrsWeightBounds = [][2]float64{
+	{0.1, 100}, {0.1, 100}, {0.1, 100}, {0.1, 100},
+	{1, 10}, {0.1, 5}, {0.1, 5}, {0, 0.75},
+	{0, 4.5}, {0, 0.8}, {0.01, 3.5}, {0.1, 5},
+	{0.01, 0.2}, {0.01, 0.9}, {0.01, 3}, {0, 1}, {1, 6},
+}
+
+type MemoryState struct {
+	Stability  float64 // days until recall probability drops to 90%
+	Difficulty float64 // 1 (easy) to 10 (hard)
+}
+
+func GradeFromCorrect(correct bool) int {
+	if correct {
+		return GradeGood
+	}
+	return GradeAgain
+}
+
+// GradeAnswer treats a slow correct answer as a sign the card is about to be
+// forgotten. slowAfter 0 disables this.
+func GradeAnswer(correct bool, duration time.Duration, slowAfter time.Duration) int {
+	if correct && slowAfter > 0 && duration > slowAfter {
+		return GradeHard
+	}
+	return GradeFromCorrect(correct)
+}
+
+// ValidFSRSWeights returns the default weights when w is missing or malformed
+func ValidFSRSWeights(w []float64) []float64 {
+	if len(w) != len(DefaultFSRSWeights) {
+		return DefaultFSRSWeights
+	}
+	return w
+}
+
+func Retrievability(elapsedDays float64, stability float64) float64 {
+	if stability <= 0 {
+		return 0
+	}
+	return math.Pow(1+fsrsFactor*math.Max(elapsedDays, 0)/stability, fsrsDecay)
+}
+
+func InitialMemoryState(w []float64, grade int) MemoryState {
+	return MemoryState{
+		Stability:  math.Max(w[grade-1], minStability),
+		Difficulty: clampDifficulty(initialDifficulty(w, grade)),
+	}
+}
+
+func NextMemoryState(w []float64, state MemoryState, elapsedDays float64, grade int) MemoryState {
+	r := Retrievability(elapsedDays, state.Stability)
+
+	var stability float64
+	if grade == GradeAgain {
+		stability = w[11] *
+			math.Pow(state.Difficulty, -w[12]) *
+			(math.Pow(state.Stability+1, w[13]) - 1) *
+			math.Exp(w[14]*(1-r))
+		stability = math.Min(stability, state.Stability)
+	} else {
+		hardPenalty, easyBonus := 1.0, 1.0
+		if grade == GradeHard {
+			hardPenalty = w[15]
+		}
+		if grade == GradeEasy {
+			easyBonus = w[16]
+		}
+		stability = state.Stability * (1 + math.Exp(w[8])*
+			(11-state.Difficulty)*
+			math.Pow(state.Stability, -w[9])*
+			(math.Exp(w[10]*(1-r))-1)*
+			hardPenalty*easyBonus)
+	}
+
+	difficulty := state.Difficulty - w[6]*float64(grade-GradeGood)
+	difficulty = w[7]*initialDifficulty(w, GradeGood) + (1-w[7])*difficulty
+
+	return MemoryState{
+		Stability:  math.Max(stability, minStability),
+		Difficulty: clampDifficulty(difficulty),
+	}
+}
+
+func initialDifficulty(w []float64, grade int) float64 {
+	return w[4] - float64(grade-GradeGood)*w[5]
+}
+
+func clampDifficulty(d float64) float64 {
+	return math.Min(math.Max(d, minDifficulty), maxDifficulty)
+}
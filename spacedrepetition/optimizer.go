@@ -0,0 +1,184 @@
+package spacedrepetition
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"webproject/models"
+)
+
+const minTrainingReviews = 50
+
+type TrainingReview struct {
+	ElapsedDays float64
+	Grade       int
+	Recalled    bool
+}
+
+type OptimizerConfig struct {
+	Iterations   int
+	LearningRate float64
+}
+
+func DefaultOptimizerConfig() OptimizerConfig {
+	return OptimizerConfig{Iterations: 300, LearningRate: 0.02}
+}
+
+type FitMetrics struct {
+	LogLoss float64 `json:"log_loss"`
+	RMSE    float64 `json:"rmse"`
+}
+
+type OptimizerResult struct {
+	Weights []float64  `json:"weights"`
+	Cards   int        `json:"cards"`
+	Reviews int        `json:"reviews"`
+	Before  FitMetrics `json:"before"`
+	After   FitMetrics `json:"after"`
+}
+
+// BuildTrainingSet turns a review log into one sequence per card. Cards whose
+// history starts after they left learning are skipped since their initial
+// state is unknown, and repeated learning steps are treated as short-term
+// practice that doesn't change long-term memory. Cram answers never changed
+// the schedule and are left out.
+func BuildTrainingSet(logs []models.ReviewLog) [][]TrainingReview {
+	byCard := map[uint][]models.ReviewLog{}
+	for _, l := range logs {
+		if l.Cram {
+			continue
+		}
+		byCard[l.CardID] = append(byCard[l.CardID], l)
+	}
+
+	cardIDs := make([]uint, 0, len(byCard))
+	for id := range byCard {
+		cardIDs = append(cardIDs, id)
+	}
+	sort.Slice(cardIDs, func(i, j int) bool { return cardIDs[i] < cardIDs[j] })
+
+	var set [][]TrainingReview
+	for _, id := range cardIDs {
+		history := byCard[id]
+		sort.SliceStable(history, func(i, j int) bool {
+			return history[i].ReviewedAt.Before(history[j].ReviewedAt)
+		})
+		if history[0].Stage != "learning" {
+			continue
+		}
+
+		sequence := []TrainingReview{{Grade: LoggedGrade(history[0]), Recalled: history[0].Correct}}
+		for i := 1; i < len(history); i++ {
+			if history[i].Stage == "learning" {
+				continue
+			}
+			sequence = append(sequence, TrainingReview{
+				ElapsedDays: history[i].ReviewedAt.Sub(history[i-1].ReviewedAt).Hours() / 24,
+				Grade:       LoggedGrade(history[i]),
+				Recalled:    history[i].Correct,
+			})
+		}
+		if len(sequence) > 1 {
+			set = append(set, sequence)
+		}
+	}
+	return set
+}
+
+// Logs written before grades were recorded only know right or wrong
+func LoggedGrade(l models.ReviewLog) int {
+	if l.Grade == 0 {
+		return GradeFromCorrect(l.Correct)
+	}
+	return l.Grade
+}
+
+func EvaluateFSRS(w []float64, set [][]TrainingReview) FitMetrics {
+	var logLoss, squared float64
+	var n int
+
+	for _, sequence := range set {
+		state := InitialMemoryState(w, sequence[0].Grade)
+		for _, review := range sequence[1:] {
+			r := Retrievability(review.ElapsedDays, state.Stability)
+			r = math.Min(math.Max(r, 1e-4), 1-1e-4)
+
+			y := 0.0
+			if review.Recalled {
+				y = 1
+			}
+			logLoss -= y*math.Log(r) + (1-y)*math.Log(1-r)
+			squared += (y - r) * (y - r)
+			n++
+
+			state = NextMemoryState(w, state, review.ElapsedDays, review.Grade)
+		}
+	}
+
+	if n == 0 {
+		return FitMetrics{}
+	}
+	return FitMetrics{LogLoss: logLoss / float64(n), RMSE: math.Sqrt(squared / float64(n))}
+}
+
+// OptimizeFSRS fits the weights with Adam on numerical gradients of the log
+// loss. It is slow compared to autodiff but has no dependencies and review
+// histories of a single deck are small.
+func OptimizeFSRS(set [][]TrainingReview, initial []float64, cfg OptimizerConfig) (OptimizerResult, error) {
+	initial = ValidFSRSWeights(initial)
+
+	result := OptimizerResult{Cards: len(set)}
+	for _, sequence := range set {
+		result.Reviews += len(sequence) - 1
+	}
+	if result.Reviews < minTrainingReviews {
+		return result, fmt.Errorf("need at least %d reviews to optimise, have %d", minTrainingReviews, result.Reviews)
+	}
+
+	const (
+		beta1   = 0.9
+		beta2   = 0.999
+		epsilon = 1e-8
+	)
+
+	w := append([]float64(nil), initial...)
+	m := make([]float64, len(w))
+	v := make([]float64, len(w))
+	grad := make([]float64, len(w))
+
+	best := append([]float64(nil), w...)
+	bestLoss := EvaluateFSRS(w, set).LogLoss
+	result.Before = EvaluateFSRS(w, set)
+
+	for step := 1; step <= cfg.Iterations; step++ {
+		for i := range w {
+			h := 1e-4 * math.Max(math.Abs(w[i]), 1)
+			original := w[i]
+			w[i] = original + h
+			up := EvaluateFSRS(w, set).LogLoss
+			w[i] = original - h
+			down := EvaluateFSRS(w, set).LogLoss
+			w[i] = original
+			grad[i] = (up - down) / (2 * h)
+		}
+
+		for i := range w {
+			m[i] = beta1*m[i] + (1-beta1)*grad[i]
+			v[i] = beta2*v[i] + (1-beta2)*grad[i]*grad[i]
+			mHat := m[i] / (1 - math.Pow(beta1, float64(step)))
+			vHat := v[i] / (1 - math.Pow(beta2, float64(step)))
+			// step relative to the weight's magnitude, they range from 0.01 to 100
+			w[i] -= cfg.LearningRate * math.Max(math.Abs(w[i]), 0.1) * mHat / (math.Sqrt(vHat) + epsilon)
+			w[i] = math.Min(math.Max(w[i], fsrsWeightBounds[i][0]), fsrsWeightBounds[i][1])
+		}
+
+		if loss := EvaluateFSRS(w, set).LogLoss; loss < bestLoss {
+			bestLoss = loss
+			copy(best, w)
+		}
+	}
+
+	result.Weights = best
+	result.After = EvaluateFSRS(best, set)
+	return result, nil
+}
@@ -8,6 +8,15 @@ import (
 	"webproject/models"
 )
 
+const (
+	DefaultDesiredRetention = 0.9
+	MinDesiredRetention     = 0.7
+	MaxDesiredRetention     = 0.99
+
+	minReviewInterval = 4 * time.Hour
+	maxIntervalDays   = 36500
+)
+
 func IsAnswerCorrectInLowerCase(userAnswer string, databaseAnswer string) bool {
 	return strings.EqualFold(strings.TrimSpace(userAnswer), (strings.TrimSpace(databaseAnswer)))
 }
@@ -41,10 +50,39 @@ func GetNextEaseLevel(currentEase int, growthfactor float64) int {
 	return nextEase
 }
 
-// Used for cards already in review
-func CreateNextReviewDueDate(ease int) time.Time {
-	// Base review delay
-	base := 4.0 // hours
-	delay := time.Duration(base*math.Pow(float64(ease), 1.1)) * time.Hour
-	return time.Now().UTC().Add(delay)
+// Used for cards already in review. Intervals are the time until recall is
+// expected to fall to the deck's desired retention.
+func NextReviewInterval(stability float64, desiredRetention float64) time.Duration {
+	if desiredRetention <= 0 || desiredRetention >= 1 {
+		desiredRetention = DefaultDesiredRetention
+	}
+	days := stability / fsrsFactor * (math.Pow(desiredRetention, 1/fsrsDecay) - 1)
+	days = math.Min(days, maxIntervalDays)
+
+	interval := time.Duration(days * float64(24*time.Hour))
+	return max(interval, minReviewInterval)
+}
+
+// Cards answered before scheduling used FSRS only have an ease, so their old
+// ease^1.1 interval is taken as the point where recall drops to 90%.
+func CardMemoryState(w []float64, card models.Card) MemoryState {
+	if card.Stability > 0 {
+		return MemoryState{Stability: card.Stability, Difficulty: card.Difficulty}
+	}
+	legacyHours := 4.0 * math.Pow(float64(max(card.Ease, 1)), 1.1)
+	return MemoryState{
+		Stability:  legacyHours / 24,
+		Difficulty: clampDifficulty(initialDifficulty(w, GradeGood)),
+	}
+}
+
+// CurrentRetrievability estimates the chance of recalling the card right now.
+// Cards that have never been answered have no estimate.
+func CurrentRetrievability(w []float64, card models.Card, now time.Time) (float64, bool) {
+	if card.LastReviewDate.IsZero() || (card.Stage != "review" && card.Stability == 0) {
+		return 0, false
+	}
+	state := CardMemoryState(w, card)
+	elapsedDays := now.Sub(card.LastReviewDate).Hours() / 24
+	return Retrievability(elapsedDays, state.Stability), true
 }